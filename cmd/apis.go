@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/ratelimit"
+
+	prospety "github.com/bjornpagen/prospety-go"
+	mediadownloader "github.com/bjornpagen/youtube-apis/mediadownloader"
+	transcriptor "github.com/bjornpagen/youtube-apis/transcriptor"
+)
+
+// ProspectAPI is the part of the Prospety API merge uses.
+type ProspectAPI interface {
+	GetSearches(ctx context.Context) ([]prospety.Search, error)
+	GetProspects(ctx context.Context, searchID int) ([]prospety.Prospect, error)
+}
+
+// VideoAPI lists the videos of a YouTube channel, newest first.
+type VideoAPI interface {
+	GetChannelVideos(ctx context.Context, channelID string) ([]mediadownloader.Video, error)
+}
+
+// TranscriptAPI gets the transcript of a YouTube video.
+type TranscriptAPI interface {
+	GetTranscript(ctx context.Context, videoID string) (*transcriptor.GetTranscriptResponse, error)
+}
+
+// The Prospety and youtube-apis clients take no context, and the latter
+// take options whose types can't be named outside their packages, so they
+// need adapters to satisfy the interfaces. Each call gets its own client,
+// whose transport sends the call's requests with its context, so timeouts
+// and interrupts cancel them. The youtube-apis errors only carry the
+// response body, so their transport also records the status code.
+
+type prospetyAPI struct {
+	key string
+}
+
+func (a prospetyAPI) client(ctx context.Context) (*prospety.Client, error) {
+	c, err := prospety.New(a.key, prospety.WithHttpClient(http.Client{Transport: contextTransport{ctx: ctx, next: http.DefaultTransport}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create prospety client: %w", err)
+	}
+	return c, nil
+}
+
+func (a prospetyAPI) GetSearches(ctx context.Context) ([]prospety.Search, error) {
+	c, err := a.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetSearches()
+}
+
+func (a prospetyAPI) GetProspects(ctx context.Context, searchID int) ([]prospety.Prospect, error) {
+	c, err := a.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetProspects(searchID)
+}
+
+type mediadownloaderAPI struct {
+	key     string
+	limiter ratelimit.Limiter
+}
+
+func (a mediadownloaderAPI) GetChannelVideos(ctx context.Context, channelID string) ([]mediadownloader.Video, error) {
+	rec := &statusRecorder{next: contextTransport{ctx: ctx, next: http.DefaultTransport}}
+	c, err := mediadownloader.New(a.key,
+		mediadownloader.WithRateLimit(a.limiter),
+		mediadownloader.WithHttpClient(http.Client{Transport: rec}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mediadownloader client: %w", err)
+	}
+
+	videos, err := c.GetChannelVideos(channelID)
+	return videos, rec.wrap(err)
+}
+
+type transcriptorAPI struct {
+	key     string
+	limiter ratelimit.Limiter
+}
+
+func (a transcriptorAPI) GetTranscript(ctx context.Context, videoID string) (*transcriptor.GetTranscriptResponse, error) {
+	rec := &statusRecorder{next: contextTransport{ctx: ctx, next: http.DefaultTransport}}
+	c, err := transcriptor.New(a.key,
+		transcriptor.WithRateLimit(a.limiter),
+		transcriptor.WithHttpClient(http.Client{Transport: rec}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transcriptor client: %w", err)
+	}
+
+	transcript, err := c.GetTranscript(videoID)
+	return transcript, rec.wrap(err)
+}
+
+// contextTransport sends every request with ctx, so they are canceled
+// with it.
+type contextTransport struct {
+	ctx  context.Context
+	next http.RoundTripper
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.next.RoundTrip(req.WithContext(t.ctx))
+}
+
+// statusError is an error of an API client whose response wasn't 200 OK.
+type statusError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%v (status %d)", e.Err, e.StatusCode)
+}
+
+func (e *statusError) Unwrap() error {
+	return e.Err
+}
+
+// statusRecorder is a transport remembering the status code of the last
+// response.
+type statusRecorder struct {
+	next       http.RoundTripper
+	statusCode int
+}
+
+func (r *statusRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := r.next.RoundTrip(req)
+	if err == nil {
+		r.statusCode = res.StatusCode
+	}
+	return res, err
+}
+
+// wrap returns err as a *statusError if the response wasn't 200 OK.
+func (r *statusRecorder) wrap(err error) error {
+	if err == nil || r.statusCode == 0 || r.statusCode == http.StatusOK {
+		return err
+	}
+	return &statusError{StatusCode: r.statusCode, Err: err}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestContextTransportCancels(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// the client itself has no timeout, only the context can end the call
+	hc := http.Client{Transport: contextTransport{ctx: ctx, next: http.DefaultTransport}}
+	start := time.Now()
+	_, err := hc.Get(srv.URL)
+	if err == nil {
+		t.Fatal("the request outlived its context")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("the request took %s to cancel", d)
+	}
+	if class := classifyError(err); class != errClassTimeout {
+		t.Errorf("classifyError(%v) = %s, want %s", err, class, errClassTimeout)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+
+	airtable "github.com/bjornpagen/airtable-go"
+)
+
+// assigneeFilter selects leads by their Assignee. It is either one of the
+// keywords below, or a name or email matched case-insensitively.
+type assigneeFilter string
+
+const (
+	assigneeAny        assigneeFilter = "any"
+	assigneeUnassigned assigneeFilter = "unassigned"
+)
+
+func parseAssignee(s string) assigneeFilter {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return assigneeAny
+	}
+	return assigneeFilter(s)
+}
+
+func (a assigneeFilter) match(u *airtable.User) bool {
+	switch a {
+	case assigneeAny:
+		return true
+	case assigneeUnassigned:
+		return u == nil || (u.Id == "" && u.Email == "" && u.Name == "")
+	}
+
+	if u == nil {
+		return false
+	}
+
+	return strings.EqualFold(u.Name, string(a)) || strings.EqualFold(u.Email, string(a))
+}
+
+// user returns the airtable.User new leads should be assigned to, if any.
+// Airtable only accepts a user ID or email when writing a user field, so
+// names and keywords don't assign anyone.
+func (a assigneeFilter) user() *airtable.User {
+	if a == assigneeAny || a == assigneeUnassigned || !strings.Contains(string(a), "@") {
+		return nil
+	}
+	return &airtable.User{Email: string(a)}
+}
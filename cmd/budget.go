@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	tiktoken "github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func init() {
+	// use the encodings built into the binary instead of downloading them,
+	// so fixture runs stay offline
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+}
+
+// fallbackEncoding counts the tokens of models tiktoken doesn't know, e.g.
+// Azure deployments and local models. It is close enough for budgeting.
+const fallbackEncoding = tiktoken.MODEL_CL100K_BASE
+
+// defaultContextTokens are the context windows of the known models. Models
+// starting with one of the names, e.g. "gpt-4o-2024-05-13", share its
+// window.
+var defaultContextTokens = map[string]int{
+	"gpt-3.5-turbo": 16385,
+	"gpt-4":         8192,
+	"gpt-4-32k":     32768,
+	"gpt-4-turbo":   128000,
+	"gpt-4o":        128000,
+	"gpt-4o-mini":   128000,
+}
+
+// fallbackContextTokens is the context window of unknown models.
+const fallbackContextTokens = 4096
+
+const defaultAnswerTokens = 1024
+
+// keywordTokens bounds the channel keywords sent in the name prompt.
+const keywordTokens = 200
+
+// perMessageTokens is what the chat format adds to every message.
+const perMessageTokens = 4
+
+var (
+	encodingsMu sync.Mutex
+	encodings   = make(map[string]*tiktoken.Tiktoken)
+)
+
+// encodingFor returns the tokenizer of model.
+func encodingFor(model string) (*tiktoken.Tiktoken, error) {
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+
+	if enc, ok := encodings[model]; ok {
+		return enc, nil
+	}
+
+	enc, err := tiktoken.EncodingForModel(model)
+	if err != nil {
+		enc, err = tiktoken.GetEncoding(fallbackEncoding)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tokenizer: %w", err)
+		}
+	}
+
+	encodings[model] = enc
+	return enc, nil
+}
+
+// countTokens returns the number of tokens of s for model. If the tokenizer
+// can't be loaded it guesses, at about four characters a token.
+func countTokens(model, s string) int {
+	enc, err := encodingFor(model)
+	if err != nil {
+		return (utf8.RuneCountInString(s) + 3) / 4
+	}
+	return len(enc.EncodeOrdinary(s))
+}
+
+// contextTokens returns the context window of model.
+func (cfg LLMConfig) contextTokens(model string) int {
+	if n, ok := cfg.ContextTokens[model]; ok {
+		return n
+	}
+	if n, ok := defaultContextTokens[model]; ok {
+		return n
+	}
+
+	// the longest known name model starts with
+	best, n := "", fallbackContextTokens
+	for name, tokens := range defaultContextTokens {
+		if strings.HasPrefix(model, name+"-") && len(name) > len(best) {
+			best, n = name, tokens
+		}
+	}
+	return n
+}
+
+// promptBudget returns how many tokens a prompt to model may have, leaving
+// AnswerTokens of the context window for the answer.
+func (cfg LLMConfig) promptBudget(model string) int {
+	return cfg.contextTokens(model) - cfg.AnswerTokens
+}
+
+// requestTokens measures everything req sends to the model: the messages
+// and the tool declarations.
+func requestTokens(req openai.ChatCompletionRequest) int {
+	n := 0
+	for _, msg := range req.Messages {
+		n += perMessageTokens + countTokens(req.Model, msg.Content)
+	}
+	if len(req.Tools) > 0 {
+		if tools, err := json.Marshal(req.Tools); err == nil {
+			n += countTokens(req.Model, string(tools))
+		}
+	}
+	return n
+}
+
+// errClassPromptTooLong fails a lead whose prompt doesn't fit the model.
+// Retrying can't help.
+const errClassPromptTooLong errorClass = "prompt-too-long"
+
+// promptTooLongError is returned instead of sending a prompt that would
+// exceed the model's budget.
+type promptTooLongError struct {
+	Model  string
+	Tokens int
+	Budget int
+}
+
+func (e *promptTooLongError) Error() string {
+	return fmt.Sprintf("prompt of %d tokens exceeds the %d token budget of %s", e.Tokens, e.Budget, e.Model)
+}
+
+// checkBudget returns a *promptTooLongError if req doesn't fit its model.
+func (c *Client) checkBudget(req openai.ChatCompletionRequest) error {
+	budget := c.llmConfig.promptBudget(req.Model)
+	if tokens := requestTokens(req); tokens > budget {
+		return &promptTooLongError{Model: req.Model, Tokens: tokens, Budget: budget}
+	}
+	return nil
+}
+
+// trimToTokens cuts s to at most max tokens of model. It cuts after the
+// last sentence that fits, or between words if that would drop more than
+// half of the text, and never inside a character.
+func trimToTokens(model, s string, max int) string {
+	if max <= 0 {
+		return ""
+	}
+	enc, err := encodingFor(model)
+	if err != nil {
+		// cut at the guess of countTokens
+		if runes := []rune(s); len(runes) > max*4 {
+			return cutAtBoundary(string(runes[:max*4]))
+		}
+		return s
+	}
+
+	tokens := enc.EncodeOrdinary(s)
+	if len(tokens) <= max {
+		return s
+	}
+
+	// a token may end inside a multibyte character, drop the broken rest
+	prefix := enc.Decode(tokens[:max])
+	for len(prefix) > 0 && !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return cutAtBoundary(prefix)
+}
+
+// cutAtBoundary trims s after its last sentence end, or else after its last
+// word, as long as that keeps at least half of s.
+func cutAtBoundary(s string) string {
+	half := len(s) / 2
+
+	if i := lastSentenceEnd(s); i >= half {
+		return s[:i]
+	}
+	if i := strings.LastIndexFunc(s, unicode.IsSpace); i >= half {
+		return strings.TrimRightFunc(s[:i], unicode.IsSpace)
+	}
+	return s
+}
+
+// lastSentenceEnd returns the index just after the last sentence ending
+// punctuation of s that is followed by a space or ends s, or -1.
+func lastSentenceEnd(s string) int {
+	for i := len(s); i > 0; {
+		r, size := utf8.DecodeLastRuneInString(s[:i])
+		switch r {
+		case '.', '!', '?', '。', '！', '？':
+			if next, _ := utf8.DecodeRuneInString(s[i:]); i == len(s) || unicode.IsSpace(next) || r >= utf8.RuneSelf {
+				return i
+			}
+		}
+		i -= size
+	}
+	return -1
+}
+
+// trimKeywords keeps the leading keywords that fit in max tokens of model
+// when joined with commas. A first keyword longer than that is cut.
+func trimKeywords(model string, keywords []string, max int) []string {
+	var kept []string
+	tokens := 0
+	for _, kw := range keywords {
+		n := countTokens(model, kw) + 1
+		if tokens+n > max {
+			if len(kept) == 0 {
+				kept = append(kept, trimToTokens(model, kw, max))
+			}
+			break
+		}
+		kept = append(kept, kw)
+		tokens += n
+	}
+	return kept
+}
+
+// inputBudget returns how many tokens of input fit in the prompt name sent
+// to the model of stage, after the prompt's own text rendered with data.
+func (c *Client) inputBudget(stage, name string, data any) (int, error) {
+	prompt, _, err := c.prompts.render(name, data)
+	if err != nil {
+		return 0, err
+	}
+	model := c.llmConfig.model(stage)
+	return c.llmConfig.promptBudget(model) - perMessageTokens - countTokens(model, prompt), nil
+}
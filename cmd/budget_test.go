@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCutAtBoundary(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Hello world. Foo bar", "Hello world."},
+		{"Is it? Yes it is, mostly", "Is it? Yes it is,"},
+		{"abcdefgh ij", "abcdefgh"},
+		// cutting at the only boundary would drop more than half
+		{"ab cdefghij", "ab cdefghij"},
+		{"一句话。第二句话没有结束", "一句话。第二句话没有结束"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := cutAtBoundary(tt.in); got != tt.want {
+			t.Errorf("cutAtBoundary(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTrimToTokens(t *testing.T) {
+	const model = "gpt-4o"
+	sentences := strings.Repeat("The kettlebell rolled off the mat. ", 50)
+
+	tests := []struct {
+		name string
+		in   string
+		max  int
+	}{
+		{"fits", "The kettlebell rolled off the mat.", 100},
+		{"sentences", sentences, 40},
+		{"words", strings.Repeat("kettlebell ", 200), 25},
+		{"multibyte", strings.Repeat("日本語のテキストです。", 40), 30},
+		{"nothing", sentences, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := trimToTokens(model, tt.in, tt.max)
+
+			if n := countTokens(model, got); n > tt.max {
+				t.Errorf("got %d tokens, want at most %d", n, tt.max)
+			}
+			if !strings.HasPrefix(tt.in, got) {
+				t.Errorf("%q is not a prefix of the input", got)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("%q is not valid UTF-8", got)
+			}
+			if countTokens(model, tt.in) <= tt.max && got != tt.in {
+				t.Errorf("input that fits was trimmed to %q", got)
+			}
+		})
+	}
+
+	// whole sentences are kept when they fit
+	if got := trimToTokens(model, sentences, 40); !strings.HasSuffix(got, "mat.") {
+		t.Errorf("trimmed to %q, want it to end after a sentence", got)
+	}
+}
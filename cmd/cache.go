@@ -0,0 +1,381 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/spf13/cobra"
+)
+
+// CacheConfig sets where answers of the external APIs are cached and for
+// how long. A zero TTL never expires.
+type CacheConfig struct {
+	// Dir is the cache directory, empty disables the cache.
+	Dir string `toml:"dir"`
+	// VideosTTL is short, so refresh-openers sees new uploads soon.
+	VideosTTL      time.Duration `toml:"videos_ttl"`
+	TranscriptsTTL time.Duration `toml:"transcripts_ttl"`
+	LLMTTL         time.Duration `toml:"llm_ttl"`
+}
+
+// The kinds of cached answers, each in its own subdirectory.
+const (
+	cacheVideos      = "videos"
+	cacheTranscripts = "transcripts"
+	cacheLLM         = "llm"
+)
+
+var cacheKinds = []string{cacheVideos, cacheTranscripts, cacheLLM}
+
+const defaultCacheDir = ".outreach-cache"
+
+func defaultCacheConfig() CacheConfig {
+	return CacheConfig{
+		Dir:            defaultCacheDir,
+		VideosTTL:      time.Hour,
+		TranscriptsTTL: 30 * 24 * time.Hour,
+		LLMTTL:         30 * 24 * time.Hour,
+	}
+}
+
+func (cfg CacheConfig) validate() error {
+	if cfg.VideosTTL < 0 || cfg.TranscriptsTTL < 0 || cfg.LLMTTL < 0 {
+		return fmt.Errorf("videos_ttl, transcripts_ttl and llm_ttl must not be negative")
+	}
+	return nil
+}
+
+func (cfg CacheConfig) ttl(kind string) time.Duration {
+	switch kind {
+	case cacheVideos:
+		return cfg.VideosTTL
+	case cacheTranscripts:
+		return cfg.TranscriptsTTL
+	default:
+		return cfg.LLMTTL
+	}
+}
+
+// cachedStages are the LLM stages whose answers are cached. Openers and the
+// notes they are written from are sampled: a regenerated opener must be a
+// new one, not the one a reviewer just rejected.
+var cachedStages = map[string]bool{
+	stageName:    true,
+	stageSummary: true,
+	stageJudge:   true,
+}
+
+// diskCache stores answers as JSON files named after the hash of their
+// key: the channel ID for videos, the video ID for transcripts and the
+// model and request for LLM answers. A nil *diskCache caches nothing.
+type diskCache struct {
+	cfg CacheConfig
+}
+
+type cacheEntry struct {
+	Key     string          `json:"key"`
+	Created time.Time       `json:"created"`
+	Value   json.RawMessage `json:"value"`
+}
+
+// newDiskCache returns the cache in cfg.Dir. Its directories are created
+// with the first entry.
+func newDiskCache(cfg CacheConfig) *diskCache {
+	return &diskCache{cfg: cfg}
+}
+
+func (c *diskCache) path(kind, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.cfg.Dir, kind, hex.EncodeToString(sum[:])+".json")
+}
+
+// get decodes the answer cached under key into out, reporting whether there
+// was one that hasn't expired.
+func (c *diskCache) get(kind, key string, out any) bool {
+	if c == nil {
+		return false
+	}
+
+	data, err := os.ReadFile(c.path(kind, key))
+	if err != nil {
+		return false
+	}
+
+	var e cacheEntry
+	if err := json.Unmarshal(data, &e); err != nil || e.Key != key {
+		return false
+	}
+	if ttl := c.cfg.ttl(kind); ttl > 0 && time.Since(e.Created) > ttl {
+		return false
+	}
+
+	return json.Unmarshal(e.Value, out) == nil
+}
+
+// put caches v under key. Failing to is only logged, the answer is still
+// good.
+func (c *diskCache) put(kind, key string, v any) {
+	if c == nil {
+		return
+	}
+
+	value, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("failed to cache %s %s: %s", kind, key, err.Error())
+		return
+	}
+	data, err := json.Marshal(cacheEntry{Key: key, Created: time.Now().UTC(), Value: value})
+	if err != nil {
+		log.Printf("failed to cache %s %s: %s", kind, key, err.Error())
+		return
+	}
+
+	// write to a temporary file first, so readers never see half an entry
+	path := c.path(kind, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Printf("failed to cache %s %s: %s", kind, key, err.Error())
+		return
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		log.Printf("failed to cache %s %s: %s", kind, key, err.Error())
+		return
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		log.Printf("failed to cache %s %s: %s", kind, key, err.Error())
+	}
+}
+
+// evict deletes the answer cached under key.
+func (c *diskCache) evict(kind, key string) {
+	if c == nil {
+		return
+	}
+
+	if err := os.Remove(c.path(kind, key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("failed to evict %s %s from the cache: %s", kind, key, err.Error())
+	}
+}
+
+// cached returns the answer cached under key, or calls fn and caches its
+// answer if it succeeds. If check is set, only answers it accepts are
+// cached, and cached answers it rejects are evicted and asked for again.
+func cached[T any](c *diskCache, kind, key string, fn func() (T, error), check func(T) error) (T, error) {
+	var v T
+	if c.get(kind, key, &v) {
+		if check == nil || check(v) == nil {
+			return v, nil
+		}
+		c.evict(kind, key)
+	}
+
+	v, err := fn()
+	if err == nil && check != nil {
+		err = check(v)
+	}
+	if err == nil {
+		c.put(kind, key, v)
+	}
+	return v, err
+}
+
+// llmKey returns the cache key of req: its model and the hash of the
+// request.
+func llmKey(req openai.ChatCompletionRequest) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash request: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return req.Model + " " + hex.EncodeToString(sum[:]), nil
+}
+
+type cacheStats struct {
+	Entries int
+	Expired int
+	Bytes   int64
+	Oldest  time.Time
+	Newest  time.Time
+}
+
+// stats counts the entries of kind.
+func (c *diskCache) stats(kind string) (cacheStats, error) {
+	var s cacheStats
+	err := c.walk(kind, func(path string, e *cacheEntry, size int64) error {
+		s.Entries++
+		s.Bytes += size
+		if e == nil {
+			return nil
+		}
+		if ttl := c.cfg.ttl(kind); ttl > 0 && time.Since(e.Created) > ttl {
+			s.Expired++
+		}
+		if s.Oldest.IsZero() || e.Created.Before(s.Oldest) {
+			s.Oldest = e.Created
+		}
+		if e.Created.After(s.Newest) {
+			s.Newest = e.Created
+		}
+		return nil
+	})
+	return s, err
+}
+
+// purge deletes the entries of kind, or only the expired and unreadable
+// ones, returning how many it deleted.
+func (c *diskCache) purge(kind string, expiredOnly bool) (int, error) {
+	n := 0
+	err := c.walk(kind, func(path string, e *cacheEntry, size int64) error {
+		if expiredOnly && e != nil {
+			if ttl := c.cfg.ttl(kind); ttl == 0 || time.Since(e.Created) <= ttl {
+				return nil
+			}
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to purge cache: %w", err)
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// walk calls fn with every entry file of kind. Unreadable entries are
+// passed as nil.
+func (c *diskCache) walk(kind string, fn func(path string, e *cacheEntry, size int64) error) error {
+	dir := filepath.Join(c.cfg.Dir, kind)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read cache: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+
+		info, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf("failed to read cache: %w", err)
+		}
+
+		var e *cacheEntry
+		if data, err := os.ReadFile(path); err == nil {
+			e = new(cacheEntry)
+			if json.Unmarshal(data, e) != nil {
+				e = nil
+			}
+		}
+
+		if err := fn(path, e, info.Size()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var _cachePurgeExpired bool
+
+func init() {
+	cachePurgeCmd.Flags().BoolVar(&_cachePurgeExpired, "expired", false, "only delete expired entries")
+}
+
+// cacheArgs returns the kinds named in args, or all of them.
+func cacheArgs(args []string) ([]string, error) {
+	if _cacheConfig.Dir == "" {
+		return nil, fmt.Errorf("the cache is disabled, set --cache-dir or cache.dir")
+	}
+	if len(args) == 0 {
+		return cacheKinds, nil
+	}
+	for _, kind := range args {
+		switch kind {
+		case cacheVideos, cacheTranscripts, cacheLLM:
+		default:
+			return nil, fmt.Errorf("unknown cache %q (known: %s)", kind, strings.Join(cacheKinds, ", "))
+		}
+	}
+	return args, nil
+}
+
+func runCacheInfo(cmd *cobra.Command, args []string) {
+	kinds, err := cacheArgs(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	c := newDiskCache(_cacheConfig)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CACHE\tENTRIES\tEXPIRED\tSIZE\tTTL\tOLDEST\tNEWEST")
+	for _, kind := range kinds {
+		s, err := c.stats(kind)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ttl := "never"
+		if t := c.cfg.ttl(kind); t > 0 {
+			ttl = t.String()
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\t%s\n", kind, s.Entries, s.Expired, formatBytes(s.Bytes), ttl, formatCacheTime(s.Oldest), formatCacheTime(s.Newest))
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runCachePurge(cmd *cobra.Command, args []string) {
+	kinds, err := cacheArgs(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	c := newDiskCache(_cacheConfig)
+
+	for _, kind := range kinds {
+		n, err := c.purge(kind, _cachePurgeExpired)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("deleted %d %s entries\n", n, kind)
+	}
+}
+
+func formatCacheTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.DateTime)
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCachedCheck(t *testing.T) {
+	c := newDiskCache(CacheConfig{Dir: t.TempDir()})
+	errBad := errors.New("bad answer")
+	check := func(v string) error {
+		if v == "bad" {
+			return errBad
+		}
+		return nil
+	}
+
+	calls := 0
+	answer := func(v string) func() (string, error) {
+		return func() (string, error) {
+			calls++
+			return v, nil
+		}
+	}
+
+	// rejected answers aren't cached
+	if _, err := cached(c, cacheLLM, "key", answer("bad"), check); !errors.Is(err, errBad) {
+		t.Fatalf("cached() error = %v, want %v", err, errBad)
+	}
+	if v, err := cached(c, cacheLLM, "key", answer("good"), check); err != nil || v != "good" || calls != 2 {
+		t.Fatalf("cached() = %q, %v after %d calls, want a new answer", v, err, calls)
+	}
+	if v, _ := cached(c, cacheLLM, "key", answer("other"), check); v != "good" || calls != 2 {
+		t.Fatalf("cached() = %q after %d calls, want the cached answer", v, calls)
+	}
+
+	// cached answers that are rejected now are evicted
+	c.put(cacheLLM, "key", "bad")
+	if v, err := cached(c, cacheLLM, "key", answer("good"), check); err != nil || v != "good" || calls != 3 {
+		t.Fatalf("cached() = %q, %v after %d calls, want a new answer", v, err, calls)
+	}
+	var v string
+	if !c.get(cacheLLM, "key", &v) || v != "good" {
+		t.Errorf("cache holds %q, want the new answer", v)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+type openerJudgePromptData struct {
+	// Notes are the answers to the opener-notes prompt.
+	Notes      string
+	Candidates []judgeCandidate
+}
+
+// judgeCandidate is an opener to judge, numbered from 1 like the rubric the
+// judge reads before it.
+type judgeCandidate struct {
+	Number int
+	Opener string
+}
+
+// openerJudgement is the answer of the opener-judge prompt.
+type openerJudgement struct {
+	Scores []struct {
+		Candidate int     `json:"candidate"`
+		Score     float64 `json:"score"`
+		Reason    string  `json:"reason"`
+	} `json:"scores"`
+}
+
+var openerJudgementOutput = structuredOutput{
+	Name:        "score_openers",
+	Description: "Record the score of every opener candidate.",
+	Schema: jsonSchema{
+		Type: "object",
+		Properties: map[string]jsonSchema{
+			"scores": {
+				Type: "array",
+				Items: &jsonSchema{
+					Type: "object",
+					Properties: map[string]jsonSchema{
+						"candidate": {Type: "integer", Description: "the number of the candidate, from 1"},
+						"score":     {Type: "integer", Description: "the score from 1 to 10"},
+						"reason":    {Type: "string", Description: "why, in one short sentence"},
+					},
+					Required: []string{"candidate", "score", "reason"},
+				},
+			},
+		},
+		Required: []string{"scores"},
+	},
+}
+
+// How much the judge's score weighs against the specificity of the opener.
+const judgeWeight = 0.7
+
+// specificWords is the number of transcript words that makes an opener
+// fully specific.
+const specificWords = 5
+
+type scoredOpener struct {
+	Opener string
+	Score  float64
+}
+
+// rankOpeners orders candidates best first. Every candidate is scored by
+// how many transcript details it mentions, and by the LLM judge if there is
+// more than one and it is enabled. It also returns the judge prompt version,
+// empty if the judge wasn't asked. A failing judge only leaves the
+// heuristics.
+func (c *Client) rankOpeners(ctx context.Context, notes, transcript string, candidates []string) ([]string, string) {
+	scored := make([]scoredOpener, len(candidates))
+	for i, opener := range candidates {
+		scored[i] = scoredOpener{Opener: opener, Score: c.specificity(opener, transcript)}
+	}
+
+	var version string
+	if c.openerRules.Judge && len(candidates) > 1 {
+		judged, v, err := c.judgeOpeners(ctx, notes, candidates)
+		if err != nil {
+			log.Printf("failed to judge openers, ranking by specificity only: %s", err.Error())
+		} else {
+			version = v
+			for i := range scored {
+				scored[i].Score = judgeWeight*judged[i] + (1-judgeWeight)*scored[i].Score
+			}
+		}
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool { return scored[i].Score > scored[j].Score })
+
+	ranked := make([]string, len(scored))
+	for i, s := range scored {
+		ranked[i] = s.Opener
+	}
+	return ranked, version
+}
+
+// judgeOpeners asks the LLM to score every candidate, returning the scores
+// scaled to 0-1 in the order of candidates.
+func (c *Client) judgeOpeners(ctx context.Context, notes string, candidates []string) ([]float64, string, error) {
+	data := openerJudgePromptData{Notes: notes}
+	for i, opener := range candidates {
+		data.Candidates = append(data.Candidates, judgeCandidate{Number: i + 1, Opener: opener})
+	}
+	prompt, version, err := c.prompts.render(promptOpenerJudge, data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	judgement := &openerJudgement{}
+	check := func() error {
+		_, err := judgement.scores(len(candidates))
+		return err
+	}
+	if err := c.gptStructured(ctx, stageJudge, prompt, openerJudgementOutput, judgement, check); err != nil {
+		return nil, "", err
+	}
+
+	scores, _ := judgement.scores(len(candidates))
+	return scores, version, nil
+}
+
+// scores returns the scores of n candidates scaled to 0-1, in their order.
+// A judgement that doesn't score every candidate exactly once is invalid:
+// a judge numbering from 0 would shift every score by one.
+func (j *openerJudgement) scores(n int) ([]float64, error) {
+	if len(j.Scores) != n {
+		return nil, fmt.Errorf("%w: scored %d of %d candidates", errInvalidOutput, len(j.Scores), n)
+	}
+
+	scores := make([]float64, n)
+	scored := make([]bool, n)
+	for _, s := range j.Scores {
+		i := s.Candidate - 1
+		if i < 0 || i >= n {
+			return nil, fmt.Errorf("%w: scored candidate %d, there are %d", errInvalidOutput, s.Candidate, n)
+		}
+		if scored[i] {
+			return nil, fmt.Errorf("%w: scored candidate %d twice", errInvalidOutput, s.Candidate)
+		}
+		scored[i] = true
+
+		score := s.Score / 10
+		if score < 0 {
+			score = 0
+		} else if score > 1 {
+			score = 1
+		}
+		scores[i] = score
+	}
+
+	return scores, nil
+}
+
+// specificity is the share of specificWords transcript details opener
+// mentions after the prefix, from 0 to 1.
+func (c *Client) specificity(opener, transcript string) float64 {
+	n := mentionedDetails(strings.TrimPrefix(opener, c.openerRules.Prefix), transcript)
+	if n >= specificWords {
+		return 1
+	}
+	return float64(n) / specificWords
+}
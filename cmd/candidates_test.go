@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestOpenerJudgementScores(t *testing.T) {
+	tests := []struct {
+		name   string
+		answer string
+		want   []float64
+	}{
+		{
+			name:   "every candidate once",
+			answer: `{"scores": [{"candidate": 2, "score": 9}, {"candidate": 1, "score": 6}, {"candidate": 3, "score": 12}]}`,
+			want:   []float64{0.6, 0.9, 1},
+		},
+		{
+			name:   "numbered from 0",
+			answer: `{"scores": [{"candidate": 0, "score": 6}, {"candidate": 1, "score": 9}, {"candidate": 2, "score": 7}]}`,
+		},
+		{
+			name:   "candidate skipped",
+			answer: `{"scores": [{"candidate": 1, "score": 6}, {"candidate": 2, "score": 9}]}`,
+		},
+		{
+			name:   "candidate twice",
+			answer: `{"scores": [{"candidate": 1, "score": 6}, {"candidate": 1, "score": 9}, {"candidate": 3, "score": 7}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var j openerJudgement
+			if err := json.Unmarshal([]byte(tt.answer), &j); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := j.scores(3)
+			if tt.want == nil {
+				if !errors.Is(err, errInvalidOutput) {
+					t.Errorf("scores() = %v, %v, want an invalid output error", got, err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("scores() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
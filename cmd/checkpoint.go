@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	airtable "github.com/bjornpagen/airtable-go"
+)
+
+// journal is an append-only JSON lines file of every computed result and
+// every successful write of results. Results that were computed but never
+// written are replayed on the next run, so a crash doesn't lose them.
+type journal struct {
+	path string
+
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+type journalEntry struct {
+	Result  *airtable.Record[Lead] `json:"result,omitempty"`
+	From    Status                 `json:"from,omitempty"`
+	Flushed []string               `json:"flushed,omitempty"`
+}
+
+const defaultJournalPath = ".outreach-journal.jsonl"
+
+func openJournal(path string) (*journal, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+
+	return &journal{path: path, f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (j *journal) append(e journalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.enc.Encode(e); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+
+	// a result must survive a crash right after it was computed
+	return j.f.Sync()
+}
+
+// pending returns the results that were journaled but never flushed, and
+// the status each lead had when it was computed.
+func (j *journal) pending() ([]airtable.Record[Lead], map[string]Status, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	f, err := os.Open(j.path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer f.Close()
+
+	results := make(map[string]airtable.Record[Lead])
+	from := make(map[string]Status)
+	var order []string
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var e journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// the last line may be torn by a crash mid-write
+			log.Printf("skipping bad journal line: %s", err.Error())
+			continue
+		}
+
+		if e.Result != nil && e.Result.Fields != nil {
+			if _, ok := results[e.Result.ID]; !ok {
+				order = append(order, e.Result.ID)
+			}
+			results[e.Result.ID] = *e.Result
+			from[e.Result.ID] = e.From
+		}
+		for _, id := range e.Flushed {
+			delete(results, id)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	var records []airtable.Record[Lead]
+	for _, id := range order {
+		if rec, ok := results[id]; ok {
+			records = append(records, rec)
+		}
+	}
+
+	return records, from, nil
+}
+
+// reset empties the journal once everything in it was flushed.
+func (j *journal) reset() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.f.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate journal: %w", err)
+	}
+	return nil
+}
+
+func (j *journal) Close() error {
+	return j.f.Close()
+}
+
+// replayJournal opens the journal and writes the results a previous run
+// computed but didn't write. It runs before leads are listed, so they
+// aren't generated twice. The leads may have changed since, so results are
+// checked against their current status; those that still can't be written
+// stay in the journal, results of deleted leads are dropped.
+func (c *Client) replayJournal() error {
+	if c.journalPath == "" {
+		return nil
+	}
+
+	if c.journal == nil {
+		var err error
+		c.journal, err = openJournal(c.journalPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	records, _, err := c.journal.pending()
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return c.journal.reset()
+	}
+
+	log.Printf("replaying %d unwritten results from %s", len(records), c.journal.path)
+
+	pending := make(map[string]bool, len(records))
+	for _, rec := range records {
+		pending[rec.ID] = true
+	}
+	leads, err := retry(context.Background(), c.retry, "list leads", func(ctx context.Context) ([]airtable.Record[Lead], error) {
+		return callWithContext(ctx, func() ([]airtable.Record[Lead], error) {
+			return c.leadDb.List(nil)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to replay journal: %w", err)
+	}
+	var current []airtable.Record[Lead]
+	for _, lead := range leads {
+		if pending[lead.ID] {
+			current = append(current, lead)
+		}
+	}
+	from := statusIndex(current)
+
+	var existing []airtable.Record[Lead]
+	for _, rec := range records {
+		if _, ok := from[rec.ID]; !ok {
+			log.Printf("dropping the journaled result of lead %s, it doesn't exist anymore", rec.ID)
+			continue
+		}
+		existing = append(existing, rec)
+	}
+
+	skipped, err := c.updateLeads(from, existing)
+	if err != nil {
+		return fmt.Errorf("failed to replay journal: %w", err)
+	}
+
+	if err := c.journal.reset(); err != nil {
+		return err
+	}
+	if len(skipped) > 0 {
+		log.Printf("%d journaled results don't fit their leads' status, keeping them in %s", len(skipped), c.journal.path)
+	}
+	for i := range skipped {
+		if err := c.journal.append(journalEntry{Result: &skipped[i], From: from[skipped[i].ID]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// leadWriter writes results to the lead store in batches as they come in,
+// journaling them first when the Client has a journal.
+type leadWriter struct {
+	c    *Client
+	from map[string]Status
+
+	mu      sync.Mutex
+	pending []airtable.Record[Lead]
+	failed  bool
+	skipped int
+}
+
+// airtableBatchSize is the most records Airtable takes per request.
+const airtableBatchSize = 10
+
+func (c *Client) newLeadWriter(from map[string]Status) *leadWriter {
+	return &leadWriter{c: c, from: from}
+}
+
+// add queues rec, writing a batch once there are enough queued. It is safe
+// to call from several workers.
+func (w *leadWriter) add(rec airtable.Record[Lead]) {
+	if w.c.journal != nil {
+		if err := w.c.journal.append(journalEntry{Result: &rec, From: w.from[rec.ID]}); err != nil {
+			log.Printf("failed to journal lead %s: %s", rec.ID, err.Error())
+		}
+	}
+
+	var batch []airtable.Record[Lead]
+	w.mu.Lock()
+	w.pending = append(w.pending, rec)
+	if len(w.pending) >= airtableBatchSize {
+		batch = w.pending
+		w.pending = nil
+	}
+	w.mu.Unlock()
+
+	if batch != nil {
+		w.write(batch)
+	}
+}
+
+func (w *leadWriter) write(batch []airtable.Record[Lead]) {
+	skipped, err := w.c.updateLeads(w.from, batch)
+	if err != nil {
+		// the results stay in the journal and are replayed next run
+		log.Printf("failed to update %d leads: %s", len(batch), err.Error())
+		w.mu.Lock()
+		w.failed = true
+		w.mu.Unlock()
+		return
+	}
+
+	// skipped results aren't flushed, they stay in the journal too
+	w.mu.Lock()
+	w.skipped += len(skipped)
+	w.mu.Unlock()
+	unwritten := make(map[string]bool, len(skipped))
+	for _, rec := range skipped {
+		unwritten[rec.ID] = true
+	}
+
+	if w.c.journal != nil {
+		var ids []string
+		for _, rec := range batch {
+			if !unwritten[rec.ID] {
+				ids = append(ids, rec.ID)
+			}
+		}
+		if err := w.c.journal.append(journalEntry{Flushed: ids}); err != nil {
+			log.Printf("failed to journal written leads: %s", err.Error())
+		}
+	}
+}
+
+// Close writes whatever is still queued. The journal is emptied when every
+// result was written.
+func (w *leadWriter) Close() error {
+	w.mu.Lock()
+	batch := w.pending
+	w.pending = nil
+	w.mu.Unlock()
+
+	if len(batch) > 0 {
+		w.write(batch)
+	}
+
+	if w.failed {
+		return errors.New("some leads failed to update, rerun to replay them from the journal")
+	}
+
+	if w.c.journal == nil {
+		return nil
+	}
+	if w.skipped > 0 {
+		log.Printf("%d results didn't fit their leads' status, keeping them in %s for the next run", w.skipped, w.c.journal.path)
+		return nil
+	}
+	return w.c.journal.reset()
+}
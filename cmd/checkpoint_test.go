@@ -0,0 +1,169 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	airtable "github.com/bjornpagen/airtable-go"
+)
+
+func TestJournalPending(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j, err := openJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	result := func(id, opener string, status Status) journalEntry {
+		return journalEntry{
+			Result: &airtable.Record[Lead]{ID: id, Fields: &Lead{Opener: airtable.ShortText(opener), Status: StatusSuccessOpener}},
+			From:   status,
+		}
+	}
+	for _, e := range []journalEntry{
+		result("rec1", "first", StatusReadyOpener),
+		result("rec2", "second", StatusReadyOpener),
+		result("rec3", "third", StatusNeedsReview),
+		{Flushed: []string{"rec1"}},
+		// a later result for the same lead replaces the earlier one
+		result("rec2", "second again", StatusReadyOpener),
+	} {
+		if err := j.append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a crash mid-write leaves a torn last line
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"result":{"id":"rec4","fie`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	records, from, err := j.pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, rec := range records {
+		got = append(got, rec.ID+": "+string(rec.Fields.Opener))
+	}
+	if want := []string{"rec2: second again", "rec3: third"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pending() = %q, want %q", got, want)
+	}
+	if from["rec2"] != StatusReadyOpener || from["rec3"] != StatusNeedsReview {
+		t.Errorf("pending() from = %v", from)
+	}
+
+	if err := j.reset(); err != nil {
+		t.Fatal(err)
+	}
+	records, _, err = j.pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Errorf("pending() after reset = %d records, want none", len(records))
+	}
+}
+
+func TestReplayJournal(t *testing.T) {
+	dir := t.TempDir()
+	store, err := openFileLeadStore(filepath.Join(dir, "leads.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	leads, err := store.Create([]Lead{
+		{Name: "written", Status: StatusReadyOpener},
+		// moved by hand since the result was computed
+		{Name: "moved on", Status: StatusRejectedOpener},
+		{Name: "moved back", Status: StatusReadyName},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Client{leadDb: store, retry: defaultRetryPolicy(), journalPath: filepath.Join(dir, "journal.jsonl")}
+	j, err := openJournal(c.journalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.journal = j
+	defer j.Close()
+
+	result := func(id string, status, from Status) journalEntry {
+		return journalEntry{Result: &airtable.Record[Lead]{ID: id, Fields: &Lead{Status: status}}, From: from}
+	}
+	for _, e := range []journalEntry{
+		result(leads[0].ID, StatusSuccessOpener, StatusReadyOpener),
+		result(leads[1].ID, StatusSuccessOpener, StatusReadyOpener),
+		// the journaled status doesn't allow it, the current one does
+		result(leads[2].ID, StatusSuccessName, StatusReadyOpener),
+		result("recDeleted", StatusSuccessOpener, StatusReadyOpener),
+	} {
+		if err := j.append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.replayJournal(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []Status{StatusSuccessOpener, StatusRejectedOpener, StatusSuccessName} {
+		if got := getLead(t, store, leads[i].ID).Status; got != want {
+			t.Errorf("%s: status %s, want %s", leads[i].Fields.Name, got, want)
+		}
+	}
+
+	// the result that doesn't fit is kept for the next run
+	records, _, err := j.pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].ID != leads[1].ID {
+		t.Errorf("pending() after replay = %+v, want only the lead that moved on", records)
+	}
+}
+
+func TestLeadWriterKeepsSkipped(t *testing.T) {
+	dir := t.TempDir()
+	store, err := openFileLeadStore(filepath.Join(dir, "leads.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	leads, err := store.Create([]Lead{{Name: "valid", Status: StatusReadyOpener}, {Name: "invalid", Status: StatusFailedForeign}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := openJournal(filepath.Join(dir, "journal.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	c := &Client{leadDb: store, retry: defaultRetryPolicy(), journal: j}
+
+	w := c.newLeadWriter(statusIndex(leads))
+	for _, lead := range leads {
+		w.add(airtable.Record[Lead]{ID: lead.ID, Fields: &Lead{Status: StatusSuccessOpener}})
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, _, err := j.pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].ID != leads[1].ID {
+		t.Errorf("pending() = %+v, want only the skipped result", records)
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/cobra"
+)
+
+// Config is the TOML config file. Every value can be overridden by the
+// matching command line flag.
+type Config struct {
+	// Assignee selects which leads the commands work on, see parseAssignee.
+	Assignee string `toml:"assignee"`
+
+	// Concurrency is the number of leads processed at once.
+	Concurrency int        `toml:"concurrency"`
+	RateLimits  RateLimits `toml:"rate_limits"`
+
+	// RetryAttempts is the number of tries of every external call.
+	RetryAttempts int `toml:"retry_attempts"`
+
+	// CallTimeout limits every single external call, e.g. "90s".
+	CallTimeout string `toml:"call_timeout"`
+
+	// Journal is the checkpoint journal of unwritten results.
+	Journal string `toml:"journal"`
+
+	// StructuredOutput is how structured GPT answers are requested:
+	// "tools", "json" or "text".
+	StructuredOutput string `toml:"structured_output"`
+
+	// Prompts is a directory of prompt templates replacing the built-in
+	// ones, see loadPrompts.
+	Prompts string `toml:"prompts"`
+
+	// ChunkTokens is the size of the summarized parts of transcripts too
+	// long to use whole.
+	ChunkTokens int `toml:"chunk_tokens"`
+
+	LLM      LLMConfig      `toml:"llm"`
+	Opener   OpenerRules    `toml:"opener"`
+	Videos   VideoPolicy    `toml:"videos"`
+	Cache    CacheConfig    `toml:"cache"`
+	Store    StoreConfig    `toml:"store"`
+	Airtable AirtableConfig `toml:"airtable"`
+
+	// Credentials holds API keys by name, e.g. AIRTABLE_KEY. The
+	// environment and the secrets file take precedence.
+	Credentials map[string]string `toml:"credentials"`
+}
+
+type StoreConfig struct {
+	Backend string `toml:"backend"`
+	Path    string `toml:"path"`
+}
+
+// AirtableConfig locates the Airtable tables. LeadFields maps the logical
+// lead field names (see leadFields) to the column names in the leads table;
+// fields that aren't listed keep their default column.
+type AirtableConfig struct {
+	Base          string            `toml:"base"`
+	LeadsTable    string            `toml:"leads_table"`
+	ActivityTable string            `toml:"activity_table"`
+	LeadFields    map[string]string `toml:"lead_fields"`
+}
+
+const defaultConfigPath = "outreach.toml"
+
+func defaultAirtableConfig() AirtableConfig {
+	return AirtableConfig{
+		Base:          "appl2x7vwQfJClY42",
+		LeadsTable:    "tblQcKRYGoq7kIxVN",
+		ActivityTable: "tblfPpzBCMhjXRCJg",
+	}
+}
+
+// readConfig decodes the config file at path. A missing file is only an
+// error if the path was given explicitly. Unknown keys are an error, so
+// typos don't silently fall back to the defaults.
+func readConfig(path string, explicit bool) (*Config, error) {
+	cfg := &Config{Airtable: defaultAirtableConfig(), Opener: defaultOpenerRules(), Videos: defaultVideoPolicy(), Cache: defaultCacheConfig()}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	md, err := toml.Decode(string(data), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		var keys []string
+		for _, key := range undecoded {
+			keys = append(keys, key.String())
+		}
+		return nil, fmt.Errorf("unknown keys in config %s: %s", path, strings.Join(keys, ", "))
+	}
+
+	return cfg, nil
+}
+
+// validate checks the values that can't be checked by decoding alone.
+func (cfg *Config) validate() error {
+	var errs []error
+
+	if cfg.Store.Backend != "" && cfg.Store.Backend != storeAirtable && cfg.Store.Backend != storeFile {
+		errs = append(errs, fmt.Errorf("store.backend: unknown backend %q", cfg.Store.Backend))
+	}
+
+	if cfg.Concurrency < 0 {
+		errs = append(errs, fmt.Errorf("concurrency: must not be negative"))
+	}
+	if cfg.RetryAttempts < 0 {
+		errs = append(errs, fmt.Errorf("retry_attempts: must not be negative"))
+	}
+	if cfg.ChunkTokens < 0 {
+		errs = append(errs, fmt.Errorf("chunk_tokens: must not be negative"))
+	}
+	if cfg.CallTimeout != "" {
+		if d, err := time.ParseDuration(cfg.CallTimeout); err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("call_timeout: %q is not a positive duration", cfg.CallTimeout))
+		}
+	}
+	for name, rpm := range map[string]int{
+		"openai_rpm":          cfg.RateLimits.OpenAI,
+		"airtable_rpm":        cfg.RateLimits.Airtable,
+		"transcriptor_rpm":    cfg.RateLimits.Transcriptor,
+		"mediadownloader_rpm": cfg.RateLimits.Mediadownloader,
+	} {
+		if rpm < 0 {
+			errs = append(errs, fmt.Errorf("rate_limits.%s: must not be negative", name))
+		}
+	}
+
+	if cfg.StructuredOutput != "" && !validOutputMode(cfg.StructuredOutput) {
+		errs = append(errs, fmt.Errorf("structured_output: unknown mode %q", cfg.StructuredOutput))
+	}
+
+	if cfg.Prompts != "" {
+		if _, err := loadPrompts(cfg.Prompts); err != nil {
+			errs = append(errs, fmt.Errorf("prompts: %w", err))
+		}
+	}
+
+	if err := cfg.LLM.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("llm: %w", err))
+	}
+
+	if err := cfg.Opener.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("opener: %w", err))
+	}
+
+	if err := cfg.Videos.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("videos: %w", err))
+	}
+	if err := cfg.Videos.checkClaims(cfg.Opener); err != nil {
+		errs = append(errs, fmt.Errorf("videos: %w", err))
+	}
+
+	if err := cfg.Cache.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("cache: %w", err))
+	}
+
+	at := cfg.Airtable
+	if !strings.HasPrefix(at.Base, "app") {
+		errs = append(errs, fmt.Errorf("airtable.base: %q is not a base ID (app...)", at.Base))
+	}
+	if !strings.HasPrefix(at.LeadsTable, "tbl") {
+		errs = append(errs, fmt.Errorf("airtable.leads_table: %q is not a table ID (tbl...)", at.LeadsTable))
+	}
+	if !strings.HasPrefix(at.ActivityTable, "tbl") {
+		errs = append(errs, fmt.Errorf("airtable.activity_table: %q is not a table ID (tbl...)", at.ActivityTable))
+	}
+
+	if _, err := leadColumnMap(at.LeadFields); err != nil {
+		errs = append(errs, fmt.Errorf("airtable.lead_fields: %w", err))
+	}
+
+	for key := range cfg.Credentials {
+		switch key {
+		case keyProspety, keyAirtable, keyOpenAI, keyTranscriptor, keyMediadownloader:
+		default:
+			errs = append(errs, fmt.Errorf("credentials: unknown key %q", key))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// loadConfig reads the config file and copies its values into every global
+// flag that wasn't set on the command line.
+func loadConfig(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+
+	cfg, err := readConfig(_configPath, flags.Changed("config"))
+	if err != nil {
+		return err
+	}
+
+	setDefault := func(name string, dst *string, value string) {
+		if !flags.Changed(name) && value != "" {
+			*dst = value
+		}
+	}
+
+	setDefaultInt := func(name string, dst *int, value int) {
+		if !flags.Changed(name) && value != 0 {
+			*dst = value
+		}
+	}
+
+	setDefault("assignee", &_assignee, cfg.Assignee)
+	setDefault("store", &_storeBackend, cfg.Store.Backend)
+	setDefault("store-path", &_storePath, cfg.Store.Path)
+	setDefault("journal", &_journalPath, cfg.Journal)
+	setDefault("structured-output", &_outputMode, cfg.StructuredOutput)
+	setDefault("prompts", &_promptsDir, cfg.Prompts)
+	setDefault("llm", &_llm.Provider, cfg.LLM.Provider)
+	setDefault("llm-url", &_llm.BaseURL, cfg.LLM.BaseURL)
+	setDefault("name-model", &_llm.NameModel, cfg.LLM.NameModel)
+	setDefault("opener-model", &_llm.OpenerModel, cfg.LLM.OpenerModel)
+	setDefault("judge-model", &_llm.JudgeModel, cfg.LLM.JudgeModel)
+	setDefault("summary-model", &_llm.SummaryModel, cfg.LLM.SummaryModel)
+	_llm.APIVersion = cfg.LLM.APIVersion
+	_llm.ContextTokens = cfg.LLM.ContextTokens
+	_llm.AnswerTokens = cfg.LLM.AnswerTokens
+	attempts, candidates := _openerRules.Attempts, _openerRules.Candidates
+	_openerRules = cfg.Opener
+	if flags.Changed("opener-attempts") {
+		_openerRules.Attempts = attempts
+	}
+	if flags.Changed("opener-candidates") {
+		_openerRules.Candidates = candidates
+	}
+	videoFlags := _videoPolicy
+	_videoPolicy = cfg.Videos
+	if flags.Changed("skip-shorts") {
+		_videoPolicy.SkipShorts = videoFlags.SkipShorts
+	}
+	if flags.Changed("skip-live") {
+		_videoPolicy.SkipLive = videoFlags.SkipLive
+	}
+	if flags.Changed("min-video-length") {
+		_videoPolicy.MinLength = videoFlags.MinLength
+	}
+	if flags.Changed("max-video-age") {
+		_videoPolicy.MaxAge = videoFlags.MaxAge
+	}
+	if flags.Changed("popular-window") {
+		_videoPolicy.PopularWindow = videoFlags.PopularWindow
+	}
+	if flags.Changed("video-candidates") {
+		_videoPolicy.Candidates = videoFlags.Candidates
+	}
+	cacheDir := _cacheConfig.Dir
+	_cacheConfig = cfg.Cache
+	if flags.Changed("cache-dir") {
+		_cacheConfig.Dir = cacheDir
+	}
+	setDefaultInt("concurrency", &_concurrency, cfg.Concurrency)
+	setDefaultInt("retry-attempts", &_retryAttempts, cfg.RetryAttempts)
+	setDefaultInt("chunk-tokens", &_chunkTokens, cfg.ChunkTokens)
+	if !flags.Changed("call-timeout") && cfg.CallTimeout != "" {
+		d, err := time.ParseDuration(cfg.CallTimeout)
+		if err != nil {
+			return fmt.Errorf("bad call_timeout in config: %w", err)
+		}
+		_callTimeout = d
+	}
+	setDefaultInt("openai-rpm", &_rateLimits.OpenAI, cfg.RateLimits.OpenAI)
+	setDefaultInt("airtable-rpm", &_rateLimits.Airtable, cfg.RateLimits.Airtable)
+	setDefaultInt("transcriptor-rpm", &_rateLimits.Transcriptor, cfg.RateLimits.Transcriptor)
+	setDefaultInt("mediadownloader-rpm", &_rateLimits.Mediadownloader, cfg.RateLimits.Mediadownloader)
+
+	_airtableConfig = cfg.Airtable
+	_configCredentials = cfg.Credentials
+
+	return nil
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) {
+	cfg, err := readConfig(_configPath, true)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := cfg.validate(); err != nil {
+		log.Fatalf("invalid config %s:\n%v", _configPath, err)
+	}
+
+	fmt.Printf("%s is valid\n", _configPath)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Credential names, as read from the environment, the secrets file and the
+// [credentials] table of the config file.
+const (
+	keyProspety        = "PROSPETY_KEY"
+	keyAirtable        = "AIRTABLE_KEY"
+	keyOpenAI          = "OPENAI_KEY"
+	keyTranscriptor    = "TRANSCRIPTOR_KEY"
+	keyMediadownloader = "MEDIADOWNLOADER_KEY"
+)
+
+const defaultSecretsPath = "secrets.env"
+
+// resolveCredentials looks up every key in the environment, then the
+// secrets file, then the config file, and fails listing all the keys that
+// weren't found.
+func resolveCredentials(cmd *cobra.Command, keys []string) (map[string]string, error) {
+	secrets, err := readSecrets(_secretsPath, cmd.Flags().Changed("secrets"))
+	if err != nil {
+		return nil, err
+	}
+
+	creds := make(map[string]string, len(keys))
+	var missing []string
+	for _, key := range keys {
+		switch {
+		case os.Getenv(key) != "":
+			creds[key] = os.Getenv(key)
+		case secrets[key] != "":
+			creds[key] = secrets[key]
+		case _configCredentials[key] != "":
+			creds[key] = _configCredentials[key]
+		default:
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%s needs %s: set them in the environment, %s or [credentials] in %s",
+			cmd.CommandPath(), strings.Join(missing, ", "), _secretsPath, _configPath)
+	}
+
+	return creds, nil
+}
+
+// readSecrets parses a dotenv style file of KEY=VALUE lines. Blank lines
+// and lines starting with # are skipped, values may be quoted. A missing
+// file is only an error if the path was given explicitly.
+func readSecrets(path string, explicit bool) (map[string]string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open secrets file: %w", err)
+	}
+	defer f.Close()
+
+	secrets := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, n)
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		secrets[strings.TrimSpace(key)] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+
+	return secrets, nil
+}
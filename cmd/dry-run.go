@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	airtable "github.com/bjornpagen/airtable-go"
+)
+
+// dryRunLeadStore wraps a LeadStore, passing reads through and recording
+// every write instead of sending it, so the plan can be reviewed first.
+type dryRunLeadStore struct {
+	LeadStore
+
+	mu      sync.Mutex
+	seen    map[string]airtable.Record[Lead]
+	creates []Lead
+	updates []airtable.Record[Lead]
+}
+
+func newDryRunLeadStore(s LeadStore) *dryRunLeadStore {
+	return &dryRunLeadStore{
+		LeadStore: s,
+		seen:      make(map[string]airtable.Record[Lead]),
+	}
+}
+
+func (s *dryRunLeadStore) List(filter LeadFilter) ([]airtable.Record[Lead], error) {
+	records, err := s.LeadStore.List(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// remember what was read, to diff updates against it
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rec := range records {
+		s.seen[rec.ID] = copyRecord(rec)
+	}
+
+	return records, nil
+}
+
+func (s *dryRunLeadStore) Create(leads []Lead) ([]airtable.Record[Lead], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var created []airtable.Record[Lead]
+	for i := range leads {
+		lead := leads[i]
+		s.creates = append(s.creates, lead)
+		created = append(created, airtable.Record[Lead]{
+			ID:     fmt.Sprintf("dry-run-%d", len(s.creates)),
+			Fields: &lead,
+		})
+	}
+
+	return created, nil
+}
+
+func (s *dryRunLeadStore) Update(records []airtable.Record[Lead]) ([]airtable.Record[Lead], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, rec := range records {
+		s.updates = append(s.updates, copyRecord(rec))
+	}
+
+	return records, nil
+}
+
+type fieldChange struct {
+	Old json.RawMessage `json:"old,omitempty"`
+	New json.RawMessage `json:"new"`
+}
+
+type plannedUpdate struct {
+	ID      string                 `json:"id"`
+	Changes map[string]fieldChange `json:"changes"`
+}
+
+type dryRunPlan struct {
+	Create []Lead          `json:"create"`
+	Update []plannedUpdate `json:"update"`
+}
+
+func (s *dryRunLeadStore) plan() (*dryRunPlan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := &dryRunPlan{Create: s.creates}
+	for _, rec := range s.updates {
+		newFields, err := leadColumnValues(rec.Fields)
+		if err != nil {
+			return nil, err
+		}
+
+		oldFields := map[string]json.RawMessage{}
+		if old, ok := s.seen[rec.ID]; ok {
+			oldFields, err = leadColumnValues(old.Fields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// only the fields set in the patch are written, and clearing an
+		// empty field changes nothing
+		changes := make(map[string]fieldChange)
+		for column, v := range newFields {
+			old, ok := oldFields[column]
+			if !ok && string(v) == "null" {
+				continue
+			}
+			if !bytes.Equal(old, v) {
+				changes[column] = fieldChange{Old: old, New: v}
+			}
+		}
+		if len(changes) > 0 {
+			p.Update = append(p.Update, plannedUpdate{ID: rec.ID, Changes: changes})
+		}
+	}
+
+	return p, nil
+}
+
+// leadColumnValues returns the encoded value of every non-empty column.
+func leadColumnValues(lead *Lead) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(lead)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal lead: %w", err)
+	}
+
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal lead: %w", err)
+	}
+
+	return m, nil
+}
+
+// report writes the plan as JSON to path, or as text to stdout if path is
+// empty.
+func (s *dryRunLeadStore) report(path string) error {
+	p, err := s.plan()
+	if err != nil {
+		return err
+	}
+
+	if path == "" {
+		return p.print(os.Stdout)
+	}
+
+	data, err := json.MarshalIndent(p, "", "\t")
+	if err != nil {
+		return fmt.Errorf("failed to marshal dry run plan: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write dry run plan: %w", err)
+	}
+
+	return nil
+}
+
+func (p *dryRunPlan) print(w io.Writer) error {
+	fmt.Fprintf(w, "dry run: %d leads would be created, %d updated\n", len(p.Create), len(p.Update))
+
+	for i := range p.Create {
+		values, err := leadColumnValues(&p.Create[i])
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(w, "\ncreate:")
+		for _, column := range sortedKeys(values) {
+			fmt.Fprintf(w, "  + %s: %s\n", column, values[column])
+		}
+	}
+
+	for _, u := range p.Update {
+		fmt.Fprintf(w, "\nupdate %s:\n", u.ID)
+		for _, column := range sortedKeys(u.Changes) {
+			change := u.Changes[column]
+			old := string(change.Old)
+			if old == "" {
+				old = "(empty)"
+			}
+			fmt.Fprintf(w, "  ~ %s: %s -> %s\n", column, old, change.New)
+		}
+	}
+
+	return nil
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
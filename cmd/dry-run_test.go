@@ -0,0 +1,50 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	airtable "github.com/bjornpagen/airtable-go"
+)
+
+func TestDryRunPlanClearsOnlySetFailures(t *testing.T) {
+	fs, err := openFileLeadStore(filepath.Join(t.TempDir(), "leads.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := fs.Create([]Lead{{
+		Name:           "FitBob Training",
+		Status:         StatusReadyOpener,
+		FailureMessage: "transcriptor is down",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newDryRunLeadStore(fs)
+	if _, err := s.List(nil); err != nil {
+		t.Fatal(err)
+	}
+	update := &Lead{Opener: "i loved your latest video! i", Status: StatusSuccessOpener, ClearFailure: true}
+	if _, err := s.Update([]airtable.Record[Lead]{{ID: created[0].ID, Fields: update}}); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := s.plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Update) != 1 {
+		t.Fatalf("planned %d updates, want 1", len(p.Update))
+	}
+
+	// the empty failure columns stay out of the plan
+	got := sortedKeys(p.Update[0].Changes)
+	if want := []string{"Failure Message", "Opener", "Status"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("planned changes to %q, want %q", got, want)
+	}
+	if c := p.Update[0].Changes["Failure Message"]; string(c.New) != "null" {
+		t.Errorf("Failure Message changes to %s, want null", c.New)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	airtable "github.com/bjornpagen/airtable-go"
+)
+
+const offlineFixtures = "testdata/offline.json"
+
+// expectedLead is the lead an email should end up as after merge, gen-name
+// and gen-openers. Empty fields aren't checked.
+type expectedLead struct {
+	Status           Status `json:"status"`
+	InferredName     string `json:"inferred_name"`
+	InferredNiche    string `json:"inferred_niche"`
+	Opener           string `json:"opener"`
+	OpenerAlternates string `json:"opener_alternates"`
+	OpenerVideoID    string `json:"opener_video_id"`
+	FailureStage     string `json:"failure_stage"`
+	FailureClass     string `json:"failure_class"`
+}
+
+// readExpectations reads the leads expected of the fixtures at path, kept
+// next to them under "expect".
+func readExpectations(path string) (map[string]expectedLead, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var v struct {
+		Expect map[string]expectedLead `json:"expect"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return v.Expect, nil
+}
+
+// TestPipelineOffline runs the whole pipeline on a fresh lead file with
+// every external API faked from the fixtures in testdata, then checks every
+// lead against the fixtures' expectations.
+func TestPipelineOffline(t *testing.T) {
+	fx, err := readFixtures(offlineFixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect, err := readExpectations(offlineFixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expect) == 0 {
+		t.Fatal("the fixtures expect nothing")
+	}
+
+	dir := t.TempDir()
+	c, err := New("", "", "", "", "",
+		WithLeadStore(storeFile, filepath.Join(dir, "leads.json")),
+		WithJournal(filepath.Join(dir, "journal.jsonl")),
+		WithFixtures(fx),
+		// the fakes answer at once and the same every time
+		WithRateLimits(RateLimits{OpenAI: 60000, Transcriptor: 60000, Mediadownloader: 60000}),
+		WithRetryAttempts(1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	steps := []struct {
+		name string
+		run  func(ctx context.Context) error
+	}{
+		{"merge", c.mergeProspetyLeads},
+		// merging again must not duplicate leads
+		{"merge again", c.mergeProspetyLeads},
+		{"mark new leads ready for names", c.promote(StatusNew, StatusReadyName)},
+		{"gen-name", c.genName},
+		{"mark named leads ready for openers", c.promote(StatusSuccessName, StatusReadyOpener)},
+		{"gen-openers", c.genOpeners},
+		// the fixtures don't change, every opener must stay as it is
+		{"refresh-openers", c.refreshOpeners},
+	}
+	for _, step := range steps {
+		if err := step.run(ctx); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	leads, err := c.leadDb.List(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byEmail := make(map[string]*Lead, len(leads))
+	for _, lead := range leads {
+		email := string(lead.Fields.Email)
+		if _, ok := byEmail[email]; ok {
+			t.Errorf("%s: duplicate lead", email)
+		}
+		byEmail[email] = lead.Fields
+	}
+
+	for _, email := range sortedKeys(expect) {
+		want := expect[email]
+		lead, ok := byEmail[email]
+		if !ok {
+			t.Errorf("%s: no lead", email)
+			continue
+		}
+
+		check := func(field, got, want string) {
+			if want != "" && got != want {
+				t.Errorf("%s: %s is %q, want %q", email, field, got, want)
+			}
+		}
+		check("status", string(lead.Status), string(want.Status))
+		check("inferred name", string(lead.InferredName), want.InferredName)
+		check("inferred niche", string(lead.InferredNiche), want.InferredNiche)
+		check("opener", string(lead.Opener), want.Opener)
+		check("opener alternates", string(lead.OpenerAlternates), want.OpenerAlternates)
+		check("opener video", string(lead.OpenerVideoID), want.OpenerVideoID)
+		check("failure stage", string(lead.FailureStage), want.FailureStage)
+		check("failure class", string(lead.FailureClass), want.FailureClass)
+	}
+}
+
+// promote moves every lead in from to to, as a salesperson does by hand
+// between the pipeline steps.
+func (c *Client) promote(from, to Status) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		leads, err := c.leadDb.List(func(lead *Lead) bool {
+			return lead.Status == from
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list leads: %w", err)
+		}
+
+		var records []airtable.Record[Lead]
+		for _, lead := range leads {
+			records = append(records, airtable.Record[Lead]{ID: lead.ID, Fields: &Lead{Status: to}})
+		}
+
+		_, err = c.updateLeads(statusIndex(leads), records)
+		return err
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	airtable "github.com/bjornpagen/airtable-go"
+)
+
+// Pipeline stages a lead can fail in.
+const (
+	stageName       = "name"
+	stageChannel    = "channel"
+	stageVideos     = "videos"
+	stageTranscript = "transcript"
+	stageOpener     = "opener"
+)
+
+// Error classes of failures that aren't about the call itself, but about
+// what came back.
+const (
+	errClassBadData         errorClass = "bad-data"
+	errClassBadLink         errorClass = "bad-link"
+	errClassNoVideos        errorClass = "no-videos"
+	errClassNoTranscript    errorClass = "no-transcript"
+	errClassInvalidResponse errorClass = "invalid-response"
+	errClassRejected        errorClass = "rejected"
+)
+
+// stageError is a lead failure with the stage it happened in. Its message
+// is what salespeople see on the lead, so keep it plain.
+type stageError struct {
+	Stage   string
+	Class   errorClass
+	Message string
+	Err     error
+}
+
+func (e *stageError) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return e.Message + ": " + e.Err.Error()
+}
+
+func (e *stageError) Unwrap() error {
+	return e.Err
+}
+
+// failStage wraps err as a failure in stage. An empty class is taken from
+// err, see classifyError.
+func failStage(stage string, class errorClass, message string, err error) error {
+	if class == "" {
+		class = classifyError(err)
+	}
+	return &stageError{Stage: stage, Class: class, Message: message, Err: err}
+}
+
+// failedLead returns the update marking lead as failed with status, with
+// the failure details of err.
+func failedLead(status Status, lead *Lead, err error) *Lead {
+	stage := "unknown"
+	class := classifyError(err)
+	message := err.Error()
+
+	var se *stageError
+	if errors.As(err, &se) {
+		stage = se.Stage
+		message = se.Error()
+	}
+
+	return &Lead{
+		Status:         status,
+		FailureStage:   airtable.ShortText(stage),
+		FailureClass:   airtable.ShortText(class),
+		FailureMessage: airtable.LongText(message),
+		FailedAt:       airtable.ShortText(time.Now().UTC().Format(time.RFC3339)),
+		Attempts:       lead.Attempts + 1,
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	prospety "github.com/bjornpagen/prospety-go"
+	mediadownloader "github.com/bjornpagen/youtube-apis/mediadownloader"
+	transcriptor "github.com/bjornpagen/youtube-apis/transcriptor"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// fixtures are the recorded answers of every external API, served by the
+// fakes so the pipeline runs without network. testdata/offline.json is
+// the set TestPipelineOffline runs on.
+type fixtures struct {
+	Prospects []prospety.Prospect `json:"prospects"`
+	// Videos are keyed by channel ID or handle, as parsed from the link.
+	Videos map[string][]mediadownloader.Video `json:"videos"`
+	// Transcripts are keyed by video ID.
+	Transcripts map[string]*transcriptor.GetTranscriptResponse `json:"transcripts"`
+	// Completions answer prompts in order: the first one whose Match is in
+	// the prompt wins.
+	Completions []fakeCompletion `json:"completions"`
+}
+
+type fakeCompletion struct {
+	Match    string `json:"match"`
+	Response string `json:"response"`
+	// Responses, if set, answer the matching prompts in turn, starting over
+	// after the last one.
+	Responses []string `json:"responses"`
+}
+
+// readFixtures reads the fixtures at path.
+func readFixtures(path string) (*fixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+
+	fx := &fixtures{}
+	if err := json.Unmarshal(data, fx); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal fixtures: %w", err)
+	}
+
+	return fx, nil
+}
+
+// The fakes fail like the real APIs do on unknown IDs, with a status code
+// classifyError treats as a client error, so nothing is retried.
+
+type fakeProspety struct {
+	fx *fixtures
+}
+
+const fakeSearchID = 1
+
+func (f fakeProspety) GetSearches(ctx context.Context) ([]prospety.Search, error) {
+	return []prospety.Search{{ID: fakeSearchID, Title: "fixtures"}}, nil
+}
+
+func (f fakeProspety) GetProspects(ctx context.Context, searchID int) ([]prospety.Prospect, error) {
+	if searchID != fakeSearchID {
+		// prospety only reports the status
+		return nil, fmt.Errorf("request failed with status code %d", http.StatusNotFound)
+	}
+	return f.fx.Prospects, nil
+}
+
+type fakeMediadownloader struct {
+	fx *fixtures
+}
+
+func (f fakeMediadownloader) GetChannelVideos(ctx context.Context, channelID string) ([]mediadownloader.Video, error) {
+	videos, ok := f.fx.Videos[channelID]
+	if !ok {
+		return nil, fakeStatusError(http.StatusNotFound, "Channel not found")
+	}
+	return videos, nil
+}
+
+type fakeTranscriptor struct {
+	fx *fixtures
+}
+
+func (f fakeTranscriptor) GetTranscript(ctx context.Context, videoID string) (*transcriptor.GetTranscriptResponse, error) {
+	transcript, ok := f.fx.Transcripts[videoID]
+	if !ok {
+		return nil, fakeStatusError(http.StatusNotFound, "Transcript not available")
+	}
+	return transcript, nil
+}
+
+// fakeStatusError fails the way mediadownloader and transcriptor do behind
+// their adapters: the client's error only has the response body, the
+// adapter adds the status code, see statusRecorder.
+func fakeStatusError(code int, message string) error {
+	body, _ := json.Marshal(map[string]string{"message": message})
+	return &statusError{StatusCode: code, Err: fmt.Errorf("http status code is not ok: %s", body)}
+}
+
+type fakeLLM struct {
+	fx *fixtures
+
+	mu    sync.Mutex
+	calls map[int]int
+}
+
+func newFakeLLM(fx *fixtures) *fakeLLM {
+	return &fakeLLM{fx: fx, calls: make(map[int]int)}
+}
+
+func (f *fakeLLM) response(i int) string {
+	completion := f.fx.Completions[i]
+	if len(completion.Responses) == 0 {
+		return completion.Response
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := f.calls[i]
+	f.calls[i]++
+	return completion.Responses[n%len(completion.Responses)]
+}
+
+// CreateChatCompletion answers with the first completion matching the last
+// message, as a call of the forced tool if there is one.
+func (f *fakeLLM) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	if len(req.Messages) == 0 {
+		return openai.ChatCompletionResponse{}, &openai.APIError{HTTPStatusCode: http.StatusBadRequest, Message: "no messages"}
+	}
+	prompt := req.Messages[len(req.Messages)-1].Content
+
+	for i, completion := range f.fx.Completions {
+		if !strings.Contains(prompt, completion.Match) {
+			continue
+		}
+		response := f.response(i)
+
+		msg := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant}
+		if choice, ok := req.ToolChoice.(openai.ToolChoice); ok {
+			msg.ToolCalls = []openai.ToolCall{{
+				ID:   "call_fixture",
+				Type: openai.ToolTypeFunction,
+				Function: openai.FunctionCall{
+					Name:      choice.Function.Name,
+					Arguments: response,
+				},
+			}}
+		} else {
+			msg.Content = response
+		}
+
+		return openai.ChatCompletionResponse{
+			Model:   req.Model,
+			Choices: []openai.ChatCompletionChoice{{Message: msg}},
+		}, nil
+	}
+
+	return openai.ChatCompletionResponse{}, &openai.APIError{
+		HTTPStatusCode: http.StatusBadRequest,
+		Message:        "no fixture completion matches the prompt",
+	}
+}
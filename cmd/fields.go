@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// leadFields maps the logical lead field names used in the config file to
+// the default column names, which are the json tags of Lead.
+var leadFields = map[string]string{
+	"topic":             "Topic",
+	"name":              "Name",
+	"followers_k":       "Followers (K)",
+	"platform":          "Platform",
+	"link":              "Link",
+	"email":             "Email",
+	"phone":             "Phone",
+	"gob":               "Gob",
+	"opener":            "Opener",
+	"assignee":          "Assignee",
+	"status":            "Status",
+	"inferred_name":     "Inferred Name",
+	"inferred_niche":    "Inferred Niche",
+	"failure_stage":     "Failure Stage",
+	"failure_class":     "Failure Class",
+	"failure_message":   "Failure Message",
+	"failed_at":         "Failed At",
+	"attempts":          "Attempts",
+	"name_prompt":       "Name Prompt",
+	"opener_prompt":     "Opener Prompt",
+	"opener_alternates": "Opener Alternates",
+
+	"opener_video_id":             "Opener Video ID",
+	"opener_video_title":          "Opener Video Title",
+	"opener_video_published":      "Opener Video Published",
+	"opener_video_published_text": "Opener Video Published Text",
+	"opener_video_url":            "Opener Video URL",
+}
+
+// leadColumns maps default column names to the configured ones. It is set
+// once by useLeadColumns before any lead is encoded; nil means defaults.
+var leadColumns map[string]string
+
+// leadColumnMap turns the configured lead_fields into a map from default to
+// configured column names, rejecting unknown fields and duplicate columns.
+func leadColumnMap(fields map[string]string) (map[string]string, error) {
+	columns := make(map[string]string, len(leadFields))
+	for _, def := range leadFields {
+		columns[def] = def
+	}
+
+	for field, column := range fields {
+		def, ok := leadFields[field]
+		if !ok {
+			return nil, fmt.Errorf("unknown lead field %q (known: %s)", field, strings.Join(leadFieldNames(), ", "))
+		}
+		if column == "" {
+			return nil, fmt.Errorf("lead field %q has an empty column name", field)
+		}
+		columns[def] = column
+	}
+
+	seen := make(map[string]string, len(columns))
+	for def, column := range columns {
+		if other, ok := seen[column]; ok {
+			return nil, fmt.Errorf("columns for %q and %q are both %q", other, def, column)
+		}
+		seen[column] = def
+	}
+
+	return columns, nil
+}
+
+func useLeadColumns(fields map[string]string) error {
+	if len(fields) == 0 {
+		leadColumns = nil
+		return nil
+	}
+
+	columns, err := leadColumnMap(fields)
+	if err != nil {
+		return err
+	}
+
+	leadColumns = columns
+	return nil
+}
+
+func leadFieldNames() []string {
+	var names []string
+	for name := range leadFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// failureColumns are the default columns of the failure fields, see
+// Lead.ClearFailure.
+var failureColumns = []string{"Failure Stage", "Failure Class", "Failure Message", "Failed At"}
+
+// leadJSON is Lead without its json methods.
+type leadJSON Lead
+
+func (l Lead) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(leadJSON(l))
+	if err != nil || (leadColumns == nil && !l.ClearFailure) {
+		return data, err
+	}
+
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	if l.ClearFailure {
+		// omitempty leaves a column as it is, airtable clears it on null
+		for _, def := range failureColumns {
+			if _, ok := m[def]; !ok {
+				m[def] = json.RawMessage("null")
+			}
+		}
+	}
+
+	if leadColumns == nil {
+		return json.Marshal(m)
+	}
+
+	renamed := make(map[string]json.RawMessage, len(m))
+	for def, v := range m {
+		renamed[leadColumns[def]] = v
+	}
+
+	return json.Marshal(renamed)
+}
+
+func (l *Lead) UnmarshalJSON(data []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	if leadColumns == nil {
+		if err := json.Unmarshal(data, (*leadJSON)(l)); err != nil {
+			return err
+		}
+		l.clearNullFailure(m)
+		return nil
+	}
+
+	defaults := make(map[string]string, len(leadColumns))
+	for def, column := range leadColumns {
+		defaults[column] = def
+	}
+
+	// columns that aren't mapped to a lead field are dropped
+	renamed := make(map[string]json.RawMessage, len(m))
+	for column, v := range m {
+		if def, ok := defaults[column]; ok {
+			renamed[def] = v
+		}
+	}
+
+	data, err := json.Marshal(renamed)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, (*leadJSON)(l)); err != nil {
+		return err
+	}
+	l.clearNullFailure(renamed)
+	return nil
+}
+
+// clearNullFailure clears the failure fields m, keyed by default column,
+// has as null: unmarshaling null leaves a string as it is. The journal and
+// the file store rely on it to replay ClearFailure.
+func (l *Lead) clearNullFailure(m map[string]json.RawMessage) {
+	for _, def := range failureColumns {
+		if string(m[def]) != "null" {
+			continue
+		}
+		switch def {
+		case "Failure Stage":
+			l.FailureStage = ""
+		case "Failure Class":
+			l.FailureClass = ""
+		case "Failure Message":
+			l.FailureMessage = ""
+		case "Failed At":
+			l.FailedAt = ""
+		}
+		l.ClearFailure = true
+	}
+}
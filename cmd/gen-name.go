@@ -1,104 +1,126 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
-	"sync"
+	"sync/atomic"
 
 	airtable "github.com/bjornpagen/airtable-go"
 	"github.com/spf13/cobra"
 )
 
 func runGenName(cmd *cobra.Command, args []string) {
-	c, err := New(_prospetyKey, _airtableKey, _openaiKey, _transcriptorKey, _mediadownloaderKey)
+	c, err := newClient(cmd, keyOpenAI)
 	if err != nil {
 		log.Fatal(err)
 	}
-	if err := c.genName(); err != nil {
+	if err := c.genName(cmd.Context()); err != nil {
+		log.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func (c *Client) genName() error {
-	// fetch all airtable leads
-	upstreamLeads, err := c.leadDb.List()
-	if err != nil {
-		return fmt.Errorf("failed to get airtable leads: %w", err)
+func (c *Client) genName(ctx context.Context) error {
+	// write results a previous run left behind first
+	if err := c.replayJournal(); err != nil {
+		return err
 	}
 
-	// filter out all leads that already have an name
-	var leadsToGen []airtable.Record[Lead]
-	for _, lead := range upstreamLeads {
-		if lead.Fields.Status == "ready-name" && lead.Fields.Assignee.Name == "Bjorn Pagen" {
-			leadsToGen = append(leadsToGen, lead)
-		}
+	// only keep leads that are ready for a name
+	leadsToGen, err := callWithContext(ctx, func() ([]airtable.Record[Lead], error) {
+		return c.leadDb.List(func(lead *Lead) bool {
+			return lead.Status == StatusReadyName && c.assignee.match(lead.Assignee)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get airtable leads: %w", err)
 	}
 
 	log.Printf("found %d leads to generate names for", len(leadsToGen))
 
-	// generate names for all leads, concurrently
-	var wg sync.WaitGroup
-	leadsToUpdateSuccesses := make(chan airtable.Record[Lead], len(leadsToGen))
-	leadsToUpdateFailures := make(chan airtable.Record[Lead], len(leadsToGen))
-	for _, lead := range leadsToGen {
-		wg.Add(1)
-		go func(lead airtable.Record[Lead]) {
-			defer wg.Done()
-			successfullyUpdated, err := c.updateSingleName(lead.ID, lead.Fields)
-			if err != nil {
-				log.Printf("failed to update lead %s: %s", lead.ID, err.Error())
-
-				// update the status to failed
-				rec := airtable.Record[Lead]{ID: lead.ID, Fields: &Lead{Status: "failed-name"}}
-				leadsToUpdateFailures <- rec
-
-				return
-			}
-			leadsToUpdateSuccesses <- *successfullyUpdated
-		}(lead)
-	}
-
-	wg.Wait()
-	close(leadsToUpdateSuccesses)
-	close(leadsToUpdateFailures)
+	// generate names for all leads on a bounded pool of workers, writing
+	// the results in batches as they complete
+	w := c.newLeadWriter(statusIndex(leadsToGen))
+	var successes, foreign, failures atomic.Int64
+	runPool(ctx, c.concurrency, leadsToGen, func(lead airtable.Record[Lead]) {
+		successfullyUpdated, err := c.updateSingleName(ctx, lead.ID, lead.Fields)
+		if err != nil && ctx.Err() != nil {
+			// interrupted, leave the lead as it is
+			log.Printf("interrupted lead %s: %s", lead.ID, err.Error())
+			return
+		}
+		if err != nil {
+			log.Printf("failed to update lead %s: %s", lead.ID, err.Error())
 
-	// convert to slice
-	var leadsToUpdateSlice []airtable.Record[Lead]
-	for lead := range leadsToUpdateSuccesses {
-		leadsToUpdateSlice = append(leadsToUpdateSlice, lead)
-	}
+			// update the status to failed
+			failures.Add(1)
+			w.add(airtable.Record[Lead]{ID: lead.ID, Fields: failedLead(StatusFailedName, lead.Fields, err)})
 
-	// update all the leads that were successfully updated
-	if _, err := c.leadDb.Update(leadsToUpdateSlice); err != nil {
-		log.Printf("failed to update leads: %s", err.Error())
-	}
-	log.Printf("%d successful leads", len(leadsToUpdateSlice))
+			return
+		}
+		if successfullyUpdated.Fields.Status == StatusFailedForeign {
+			foreign.Add(1)
+		} else {
+			successes.Add(1)
+		}
+		w.add(*successfullyUpdated)
+	})
 
-	// convert to slice
-	var leadsToUpdateSliceFailures []airtable.Record[Lead]
-	for lead := range leadsToUpdateFailures {
-		leadsToUpdateSliceFailures = append(leadsToUpdateSliceFailures, lead)
-	}
+	log.Printf("%d successful leads", successes.Load())
+	log.Printf("%d foreign leads", foreign.Load())
+	log.Printf("%d failed leads", failures.Load())
 
-	// update all the leads that were successfully updated
-	if _, err := c.leadDb.Update(leadsToUpdateSliceFailures); err != nil {
-		log.Printf("failed to update leads: %s", err.Error())
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to update airtable leads: %w", err)
 	}
-	log.Printf("%d failed leads", len(leadsToUpdateSliceFailures))
 
 	return nil
 }
 
-func (c *Client) updateSingleName(id string, lead *Lead) (*airtable.Record[Lead], error) {
+// nameInference is the answer of the name prompt.
+type nameInference struct {
+	InferredName                  string `json:"inferred_name"`
+	InferredMainNiche             string `json:"inferred_main_niche"`
+	DetectedForeignYouTubeChannel bool   `json:"detected_foreign_youtube_channel"`
+}
+
+var nameInferenceOutput = structuredOutput{
+	Name:        "record_youtuber",
+	Description: "Record the inferred name, main niche and language of a youtuber.",
+	Schema: jsonSchema{
+		Type: "object",
+		Properties: map[string]jsonSchema{
+			"inferred_name": {
+				Type:        "string",
+				Description: "the true name of the youtuber",
+			},
+			"inferred_main_niche": {
+				Type:        "string",
+				Description: "the main niche of the channel",
+			},
+			"detected_foreign_youtube_channel": {
+				Type:        "boolean",
+				Description: "true if the channel is primarily in a non-english language",
+			},
+		},
+		Required: []string{"inferred_name", "inferred_main_niche", "detected_foreign_youtube_channel"},
+	},
+}
+
+func (c *Client) updateSingleName(ctx context.Context, id string, lead *Lead) (*airtable.Record[Lead], error) {
 	// retrieve the base64 encoded gob from the lead
 	encodedGob := lead.Gob
 
 	// decode the base64 encoded gob
 	prospect, err := decodeStringGob(string(encodedGob))
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode gob: %w", err)
+		return nil, failStage(stageName, errClassBadData, "prospect data on the lead is unreadable", err)
 	}
 
 	// generate the json GPT payload
@@ -111,7 +133,7 @@ func (c *Client) updateSingleName(id string, lead *Lead) (*airtable.Record[Lead]
 	// build our payload from the prospect
 	p := payload{
 		YouTubeName:     prospect.Name,
-		YouTubeKeywords: strings.Join(prospect.Keywords, ","),
+		YouTubeKeywords: strings.Join(trimKeywords(c.llmConfig.model(stageName), prospect.Keywords, keywordTokens), ","),
 		YouTubeEmail:    prospect.Email,
 	}
 
@@ -121,105 +143,18 @@ func (c *Client) updateSingleName(id string, lead *Lead) (*airtable.Record[Lead]
 		return nil, fmt.Errorf("failed to marshal json: %w", err)
 	}
 
-	prompt := `here is the raw json data scraped from a youtube channel. the issue with this data is that the youtube_name may not accurately represent the true name of the youtuber.
-using your inference skills with the youtube_name and youtube_email, please infer the true name of the youtuber.
-also, please infer the main niche of the youtuber.
-if the youtuber operates in a language primarily in a non-english language, please set the detected_foreign_youtube_channel to true. otherwise, leave it as false.
-your returned json object should be in the following schema:
--- input --
-{
-	"youtube_name": "NowHereBlow",
-	"youtube_keywords": "travel documentary,travel around the world,dubai mall,dubai city,tour the world,world tourism,lonely tour,abu dhabi,india,Dubai,travel vlog,world travel,kerala,nowhereblow,nhb",
-	"youtube_email": "connect@nowhereblow.com"
-}
--- output --
-{
-	"inferred_name": "NowHereBlow",
-	"inferred_main_niche": "travel",
-	"detected_foreign_youtube_channel": false
-}
--- input --
-{
-"youtube_name": "BK Crypto Trader - The Boss of Bitcoin",
-"youtube_keywords": "boss of bitcoin,crypto boss,bk bitcoin,bk crypto,btc usd,btc news,btc today,bitcoin price analysis,btc price,free btc,bitcoin price prediction,bitcoin prediction,free bitcoin,bitcoin news,btc,bitcoin price,bk,bitcoin analysis,btc analysis,free crypto,bitcoin today,bitcoin 2021,bitcoin news today,btc live,btc ta,crypto news,bitcoin live,crypto trading,bitcoin price today,crypto,btcusd,usdt,BK Crypto Trader,xrp ripple,bitcoin trading",
-"youtube_email": "bkbitcoin01@gmail.com"
-}
--- output --
-{
-"inferred_name": "BK Crypto Trader",
-"inferred_main_niche": "crypto",
-"detected_foreign_youtube_channel": false
-}
--- input --
-{
-"youtube_name": "Aaron Luján",
-"youtube_keywords": "bitcoin,xrp,btc,trading en vivo,analisis bitcoin,eth,bitcoin analisis,litecoin,scalping en vivo,analisis btc,bitcoin bajista,criptomoedas",
-"youtube_email": "aaron@paguertrading.com"
-}
--- output --
-{
-"inferred_name": "Aaron Luján",
-"inferred_main_niche": "bitcoin",
-"detected_foreign_youtube_channel": true
-}
--- input --
-{
-"youtube_name": "potatofish yu",
-"youtube_keywords": "旅遊,吃喝玩樂,分享,youtube,travel,tasmania,塔斯曼尼亞,情侶,購物,娛樂,vlog,移民生活,澳洲移民,澳洲生活,澳洲自由行,vlogger,haul,beauty,塔斯馬尼亞,lifestyle,懷孕,懷孕vlog,湊B,育兒,生產,孕婦,懷孕準備,我的湊B生活,新手爸媽,新手父母",
-"youtube_email": "potatofishyu@gmail.com"
-}
--- output --
-{
-"inferred_name": "Potatofish Yu",
-"inferred_main_niche": "旅遊",
-"detected_foreign_youtube_channel": true
-}
--- input --
-{
-	"youtube_name": "JustJordan33",
-	"youtube_keywords": "justjordan33,jordan,just jordan 33,sister,fun,cute,girl,teen,challenge,challenges,challenge videos,family,nice,friendly,happy,teen,hawaii,teenager,lds,travel,travel vlogs,jordan williams",
-	"youtube_email": "justjordan33@gmail.com"
-}
--- output --
-{
-	"inferred_name": "Jordan Williams",
-	"inferred_main_niche": "travel",
-	"detected_foreign_youtube_channel": false
-}
--- input --
-{
-	"youtube_name": "Elliott Hulse’s STRENGTH CAMP",
-	"youtube_keywords": "Elliott Hulse,Strength Training,Weightlifting,Strongman,Powerlifting,Bodybuilding",
-	"youtube_email": "colleen@elliotthulse.com"
-}
--- output --
-{
-	"inferred_name": "Elliott Hulse",
-	"inferred_main_niche": "bodybuilding",
-	"detected_foreign_youtube_channel": false
-}
---
-here is your input. please respond with only the json object. do not include any other characters.
---
-%s`
-
-	gptPayloadStr := fmt.Sprintf(prompt, string(jsonPayload))
-	// use gpt
-	gptResponse, err := c.gpt(gptPayloadStr)
+	gptPayloadStr, promptVersion, err := c.prompts.render(promptName, namePromptData{Channel: string(jsonPayload)})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get gpt response: %w", err)
-	}
-
-	// attempt to unmarshal into return payload
-	type returnPayload struct {
-		InferredName                  string `json:"inferred_name"`
-		InferredMainNiche             string `json:"inferred_main_niche"`
-		DetectedForeignYouTubeChannel bool   `json:"detected_foreign_youtube_channel"`
+		return nil, failStage(stageName, errClassPermanent, "could not render the name prompt", err)
 	}
 
-	returnPayloadObj := &returnPayload{}
-	if err := json.Unmarshal([]byte(gptResponse), returnPayloadObj); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal gpt response: %w", err)
+	// use gpt, asking for the declared schema
+	returnPayloadObj := &nameInference{}
+	if err := c.gptStructured(ctx, stageName, gptPayloadStr, nameInferenceOutput, returnPayloadObj, nil); err != nil {
+		if errors.Is(err, errInvalidOutput) {
+			return nil, failStage(stageName, errClassInvalidResponse, "GPT returned invalid JSON", err)
+		}
+		return nil, failStage(stageName, "", "GPT request failed", err)
 	}
 
 	ret := &airtable.Record[Lead]{
@@ -227,15 +162,17 @@ here is your input. please respond with only the json object. do not include any
 		Fields: &Lead{
 			InferredName:  airtable.ShortText(returnPayloadObj.InferredName),
 			InferredNiche: airtable.ShortText(strings.ToLower(returnPayloadObj.InferredMainNiche)),
+			NamePrompt:    airtable.ShortText(promptVersion),
 		},
 	}
 
 	// check if foreign bool is set, then set status to "failed-foreign"
 	if returnPayloadObj.DetectedForeignYouTubeChannel {
-		ret.Fields.Status = airtable.ShortText("failed-foreign")
+		ret.Fields.Status = StatusFailedForeign
 	} else {
 		// otherwise, set status to "success-name"
-		ret.Fields.Status = airtable.ShortText("success-name")
+		ret.Fields.Status = StatusSuccessName
+		ret.Fields.ClearFailure = true
 	}
 
 	return ret, nil
@@ -1,13 +1,14 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"net/url"
 	"regexp"
 	"strings"
-	"sync"
+	"sync/atomic"
 
 	airtable "github.com/bjornpagen/airtable-go"
 	mediadownloader "github.com/bjornpagen/youtube-apis/mediadownloader"
@@ -17,159 +18,227 @@ import (
 )
 
 func runGenOpeners(cmd *cobra.Command, args []string) {
-	c, err := New(_prospetyKey, _airtableKey, _openaiKey, _transcriptorKey, _mediadownloaderKey)
+	c, err := newClient(cmd, keyOpenAI, keyTranscriptor, keyMediadownloader)
 	if err != nil {
 		log.Fatal(err)
 	}
-	if err := c.genOpeners(); err != nil {
+	if err := c.genOpeners(cmd.Context()); err != nil {
+		log.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func (c *Client) genOpeners() error {
-	// fetch all airtable leads
-	upstreamLeads, err := c.leadDb.List()
+func (c *Client) genOpeners(ctx context.Context) error {
+	// write results a previous run left behind first
+	if err := c.replayJournal(); err != nil {
+		return err
+	}
+
+	// only keep leads that are ready for an opener
+	leadsToGen, err := callWithContext(ctx, func() ([]airtable.Record[Lead], error) {
+		return c.leadDb.List(func(lead *Lead) bool {
+			return lead.Status == StatusReadyOpener && c.assignee.match(lead.Assignee)
+		})
+	})
 	if err != nil {
 		return fmt.Errorf("failed to get airtable leads: %w", err)
 	}
 
-	// filter out all leads that already have an opener
-	var leadsToGen []airtable.Record[Lead]
-	for _, lead := range upstreamLeads {
-		if lead.Fields.Status == "ready-opener" && lead.Fields.Assignee.Name == "Bjorn Pagen" {
-			leadsToGen = append(leadsToGen, lead)
+	log.Printf("found %d leads to generate openers for", len(leadsToGen))
+
+	// generate openers for all leads on a bounded pool of workers, writing
+	// the results in batches as they complete
+	w := c.newLeadWriter(statusIndex(leadsToGen))
+	var successes, failures, needsReview atomic.Int64
+	runPool(ctx, c.concurrency, leadsToGen, func(lead airtable.Record[Lead]) {
+		successfullyUpdated, err := c.updateSingleOpener(ctx, lead.ID, lead.Fields)
+		if err != nil && ctx.Err() != nil {
+			// interrupted, leave the lead as it is
+			log.Printf("interrupted lead %s: %s", lead.ID, err.Error())
+			return
 		}
-	}
+		if err != nil {
+			log.Printf("failed to update lead %s: %s", lead.ID, err.Error())
 
-	log.Printf("found %d leads to generate openers for", len(leadsToGen))
+			// update the status to failed
+			failures.Add(1)
+			w.add(airtable.Record[Lead]{ID: lead.ID, Fields: failedLead(StatusFailedOpener, lead.Fields, err)})
 
-	// generate openers for all leads, concurrently
-	var wg sync.WaitGroup
-	leadsToUpdateSuccesses := make(chan airtable.Record[Lead], len(leadsToGen))
-	leadsToUpdateFailures := make(chan airtable.Record[Lead], len(leadsToGen))
-	for _, lead := range leadsToGen {
-		wg.Add(1)
-		go func(lead airtable.Record[Lead]) {
-			defer wg.Done()
-			successfullyUpdated, err := c.updateSingleOpener(lead.ID, lead.Fields)
-			if err != nil {
-				log.Printf("failed to update lead %s: %s", lead.ID, err.Error())
+			return
+		}
+		if successfullyUpdated.Fields.Status == StatusNeedsReview {
+			needsReview.Add(1)
+		} else {
+			successes.Add(1)
+		}
+		w.add(*successfullyUpdated)
+	})
 
-				// update the status to failed
-				rec := airtable.Record[Lead]{ID: lead.ID, Fields: &Lead{Status: "failed-opener"}}
-				leadsToUpdateFailures <- rec
+	log.Printf("%d successful leads", successes.Load())
+	log.Printf("%d leads need review", needsReview.Load())
+	log.Printf("%d failed leads", failures.Load())
 
-				return
-			}
-			leadsToUpdateSuccesses <- *successfullyUpdated
-		}(lead)
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to update airtable leads: %w", err)
 	}
 
-	// wait for all the openers to be generated
-	wg.Wait()
-	close(leadsToUpdateSuccesses)
-	close(leadsToUpdateFailures)
+	return nil
+}
 
-	// convert chan to slice
-	var leadsToUpdateSlice []airtable.Record[Lead]
-	for lead := range leadsToUpdateSuccesses {
-		leadsToUpdateSlice = append(leadsToUpdateSlice, lead)
+func (c *Client) updateSingleOpener(ctx context.Context, recordID string, lead *Lead) (*airtable.Record[Lead], error) {
+	video, transcript, err := c.openerSource(ctx, lead)
+	if err != nil {
+		return nil, err
 	}
-	log.Printf("%d successful leads", len(leadsToUpdateSlice))
 
-	// convert chan to slice
-	var leadsToUpdateFailuresSlice []airtable.Record[Lead]
-	for lead := range leadsToUpdateFailures {
-		leadsToUpdateFailuresSlice = append(leadsToUpdateFailuresSlice, lead)
+	return c.openerUpdate(ctx, recordID, lead, video, transcript)
+}
+
+// openerUpdate generates the opener of lead from video and its transcript
+// and returns the update to write: success-opener, or needs-review if the
+// openers failed the checks.
+func (c *Client) openerUpdate(ctx context.Context, recordID string, lead *Lead, video *mediadownloader.Video, transcript *transcriptor.GetTranscriptResponse) (*airtable.Record[Lead], error) {
+	// generate the opener
+	log.Printf("generating opener for %s", video.ID)
+	openers, promptVersion, err := c.genOpener(ctx, transcript)
+	var rejection *openerRejection
+	if errors.As(err, &rejection) {
+		// keep the last opener for a human to fix
+		log.Printf("opener for %s needs review: %s", video.ID, rejection.Error())
+		err = failStage(stageOpener, errClassRejected, fmt.Sprintf("opener failed the checks %d times", c.openerRules.Attempts), rejection)
+
+		fields := failedLead(StatusNeedsReview, lead, err)
+		fields.Opener = airtable.ShortText(rejection.Opener)
+		fields.OpenerAlternates = airtable.LongText(strings.Join(rejection.Alternates, "\n"))
+		fields.OpenerPrompt = airtable.ShortText(promptVersion)
+		setOpenerVideo(fields, video)
+
+		return &airtable.Record[Lead]{ID: recordID, Fields: fields}, nil
+	}
+	if err != nil {
+		log.Printf("failed to generate opener for %s: %v", video.ID, err)
+		return nil, failStage(stageOpener, "", "could not generate an opener for video "+video.ID, err)
+	}
+
+	// update the airtable lead
+	lead = &Lead{
+		Opener:           airtable.ShortText(openers[0]),
+		OpenerAlternates: airtable.LongText(strings.Join(openers[1:], "\n")),
+		OpenerPrompt:     airtable.ShortText(promptVersion),
+		Status:           StatusSuccessOpener,
+		ClearFailure:     true,
+	}
+	setOpenerVideo(lead, video)
+
+	rec := airtable.Record[Lead]{
+		ID:     recordID,
+		Fields: lead,
 	}
-	log.Printf("%d failed leads", len(leadsToUpdateFailuresSlice))
-	leadsToUpdateSlice = append(leadsToUpdateSlice, leadsToUpdateFailuresSlice...)
 
-	// update the airtable leads
-	_, err = c.leadDb.Update(leadsToUpdateSlice)
+	return &rec, nil
+}
+
+// openerSource gets the video an opener for lead is written about and its
+// transcript: the first candidate of the video policy that has one.
+func (c *Client) openerSource(ctx context.Context, lead *Lead) (*mediadownloader.Video, *transcriptor.GetTranscriptResponse, error) {
+	channelId, candidates, err := c.videoCandidates(ctx, lead)
 	if err != nil {
-		return fmt.Errorf("failed to update airtable leads: %w", err)
+		return nil, nil, err
 	}
 
-	return nil
+	// fall back to the next candidate while there is no transcript
+	for i := range candidates {
+		video := &candidates[i]
+		var transcript *transcriptor.GetTranscriptResponse
+		transcript, err = c.videoTranscript(ctx, video)
+		if err == nil {
+			return video, transcript, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if i+1 < len(candidates) {
+			log.Printf("trying the next video of %s: %s", channelId, err.Error())
+		}
+	}
+
+	return nil, nil, err
 }
 
-func (c *Client) updateSingleOpener(recordID string, lead *Lead) (*airtable.Record[Lead], error) {
+// videoCandidates returns the channel of lead and its videos an opener may
+// be written about, in the order of the video policy.
+func (c *Client) videoCandidates(ctx context.Context, lead *Lead) (string, []mediadownloader.Video, error) {
 	// use parseYoutubeChannelId(string(lead.Link))
 	// to get the channel id
 	channelId, err := parseYoutubeChannelId(string(lead.Link))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse channel id: %w", err)
+		return "", nil, failStage(stageChannel, errClassBadLink, "lead link is not a YouTube channel", err)
 	}
 
-	// get latest video
-	video, err := c.getLatestVideo(channelId)
+	// get the videos to choose from
+	videos, err := c.getChannelVideos(ctx, channelId)
 	if err != nil {
-		log.Printf("failed to get latest video for %s: %v", channelId, err)
-		return nil, fmt.Errorf("failed to get latest video for %s: %w", channelId, err)
+		log.Printf("failed to get videos for %s: %v", channelId, err)
+		return "", nil, failStage(stageVideos, "", "could not get the videos of "+channelId, err)
 	}
 
-	// get transcript
-	transcript, err := c.getTranscript(video.ID)
-	if err != nil {
-		log.Printf("failed to get transcript for video %s: %v", video.ID, err)
-		return nil, fmt.Errorf("failed to get transcript for video %s: %w", video.ID, err)
+	candidates := c.videoPolicy.candidates(videos)
+	if len(candidates) == 0 {
+		return "", nil, failStage(stageVideos, errClassNoVideos, fmt.Sprintf("none of the %d videos of %s match the video policy", len(videos), channelId), nil)
 	}
 
-	// get string of whole transcript
-	transcriptStr := transcript.String()
-	truncVal := 6000
-
-	if len(transcriptStr) == 0 {
-		log.Printf("transcript for video %s is empty", video.ID)
-		return nil, fmt.Errorf("transcript for video %s is empty", video.ID)
-	} else if len(transcriptStr) > truncVal {
-		transcriptStr = transcriptStr[:truncVal]
-		log.Printf("truncated transcript for video %s to %d chars, lead email: %s", video.ID, truncVal, lead.Email)
-	}
+	return channelId, candidates, nil
+}
 
-	// generate the opener
-	log.Printf("generating opener for %s", video.ID)
-	opener, err := c.genOpener(transcriptStr)
+// videoTranscript gets the transcript of video, failing if it is empty.
+func (c *Client) videoTranscript(ctx context.Context, video *mediadownloader.Video) (*transcriptor.GetTranscriptResponse, error) {
+	transcript, err := c.getTranscript(ctx, video.ID)
 	if err != nil {
-		log.Printf("failed to generate opener for %s: %v", transcriptStr, err)
-		return nil, fmt.Errorf("failed to generate opener for %s: %w", transcriptStr, err)
-	}
-
-	// update the airtable lead
-	lead = &Lead{
-		Opener: airtable.ShortText(opener),
-		Status: airtable.ShortText("success-opener"),
+		log.Printf("failed to get transcript for video %s: %v", video.ID, err)
+		if class := classifyError(err); class == errClassPermanent || class == errClassClient {
+			// transcriptor answers videos without captions with junk or a
+			// 4xx, server errors and timeouts say nothing about the video
+			return nil, failStage(stageTranscript, errClassNoTranscript, "no transcript available for video "+video.ID, err)
+		}
+		return nil, failStage(stageTranscript, "", "could not get the transcript of video "+video.ID, err)
 	}
 
-	rec := airtable.Record[Lead]{
-		ID:     recordID,
-		Fields: lead,
+	if len(transcript.String()) == 0 {
+		log.Printf("transcript for video %s is empty", video.ID)
+		return nil, failStage(stageTranscript, errClassNoTranscript, "transcript of video "+video.ID+" is empty", nil)
 	}
 
-	return &rec, nil
+	return transcript, nil
 }
 
-func (c *Client) getLatestVideo(channelId string) (*mediadownloader.Video, error) {
-	// use mediadownloader.GetChannelVideos(channelID string, opts ...getChannelVideosOption) ([]Video, error)
-	// get the first video
-	// return the video
-	videos, err := c.md.GetChannelVideos(channelId)
+// getChannelVideos lists the videos of a channel, newest first.
+func (c *Client) getChannelVideos(ctx context.Context, channelId string) ([]mediadownloader.Video, error) {
+	videos, err := cached(c.cache, cacheVideos, channelId, func() ([]mediadownloader.Video, error) {
+		return retry(ctx, c.retry, "get channel videos", func(ctx context.Context) ([]mediadownloader.Video, error) {
+			return c.md.GetChannelVideos(ctx, channelId)
+		})
+	}, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(videos) == 0 {
-		return nil, errors.New("no videos found")
+		return nil, failStage(stageVideos, errClassNoVideos, "channel has no videos", nil)
 	}
 
-	return &videos[0], nil
+	return videos, nil
 }
 
-func (c *Client) getTranscript(videoId string) (*transcriptor.GetTranscriptResponse, error) {
+func (c *Client) getTranscript(ctx context.Context, videoId string) (*transcriptor.GetTranscriptResponse, error) {
 	// use transcriptor.GetTranscript(videoID string, opts ...getTranscriptOption) (*GetTranscriptResponse, error)
 	// return the transcript
-	transcript, err := c.tr.GetTranscript(videoId)
+	transcript, err := cached(c.cache, cacheTranscripts, videoId, func() (*transcriptor.GetTranscriptResponse, error) {
+		return retry(ctx, c.retry, "get transcript", func(ctx context.Context) (*transcriptor.GetTranscriptResponse, error) {
+			return c.tr.GetTranscript(ctx, videoId)
+		})
+	}, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -177,52 +246,83 @@ func (c *Client) getTranscript(videoId string) (*transcriptor.GetTranscriptRespo
 	return transcript, nil
 }
 
-func (c *Client) genOpener(transcript string) (string, error) {
-	prompt1 := `Answer the following questions:
-1. what is the primary emotion that is evoked by this youtube video?
-2. what keeps the audience engaged and interested?
-3. what specific personality traits of the youtuber contribute to his/her success?
-4. why do you think this youtuber's fans love him?
-5. summarize in 3 lines the most entertaining part of this video
-6. pretend you're one of his raving fans: write a 1 line response to why you enjoyed his video so much!
-answer bullet by bullet, numbered.	
---
-%s
-	`
-
-	prompt2 := `You are now FirstLineWriterGPT. You are a raving fan of this youtuber, and his content is your favorite on the internet. Write a highly personalized "first line" in an email to the YouTuber. Demonstrate that you have watched the video with specific examples from the video. Come across as human as possible: the job with the first line is to truly demonstrate that I'm not just sending him an email sequence, but a highly personalized and target outreach manually written.
-	
-You MUST:
-1. not include any introduction, such as "hi steven,", as this is already in the email template. i only need the first line, which will be templated into my existing email sequence
-2. you cannot, under ANY CIRCUMSTANCES, give a vague or incoherent answer!
-3. do not MAKE UP ANECDOTES ABOUT YOURSELF, talk ONLY ABOUT THE CREATOR's VIDEO AND HOW GREAT HE/SHE IS AT CONTENT
-4. ONLY WRITE IN FIRST PERSON, ONLY USE PRESENT TENSE
-
-here is some info about the video to help you with your task: i asked ChatGPT these following questions, and here are his responses:
---
-%s
---
-
-REMEMBER: Start your response with:
-“i loved your latest video! i…”
-
-limit your response to 2 sentences total: cite specific events from the video and tell which was your favorite (to demonstrate you watched it).
-`
+// genOpener writes openers from transcript, best first. It also returns
+// the versions of the prompts it used.
+func (c *Client) genOpener(ctx context.Context, transcript *transcriptor.GetTranscriptResponse) ([]string, string, error) {
+	text := transcript.String()
+
+	// long transcripts are summarized part by part first
+	input, summarized, chunkVersion, err := c.transcriptNotesInput(ctx, transcript)
+	if err != nil {
+		return nil, "", err
+	}
 
 	// first call
-	content := fmt.Sprintf(prompt1, transcript)
-	res, err := c.gpt(content)
+	content, notesVersion, err := c.prompts.render(promptOpenerNotes, openerNotesPromptData{Transcript: input, Summarized: summarized})
+	if err != nil {
+		return nil, "", err
+	}
+	notes, err := c.gpt(ctx, stageOpener, content)
 	if err != nil {
-		return "", fmt.Errorf("failed to create chat completion: %w", err)
+		return nil, "", fmt.Errorf("failed to create chat completion: %w", err)
 	}
 
 	// now do the second call
-	content = fmt.Sprintf(prompt2, res)
-	res, err = c.gpt(content)
+	content, openerVersion, err := c.prompts.render(promptOpener, openerPromptData{Notes: notes})
 	if err != nil {
-		return "", fmt.Errorf("failed to generate opener: %w", err)
+		return nil, "", err
+	}
+	version := notesVersion + " " + openerVersion
+	if chunkVersion != "" {
+		version = chunkVersion + " " + version
+	}
+
+	// keep the answers, only regenerate the openers while none passes the
+	// checks
+	var rejection *openerRejection
+	for attempt := 1; attempt <= c.openerRules.Attempts; attempt++ {
+		var passed, failed []string
+		var problems []string
+		seen := make(map[string]bool)
+		for i := 0; i < c.openerRules.Candidates; i++ {
+			res, err := c.gpt(ctx, stageOpener, content)
+			if err != nil {
+				return nil, version, fmt.Errorf("failed to generate opener: %w", err)
+			}
+
+			opener := cleanOpener(res)
+			if seen[opener] {
+				continue
+			}
+			seen[opener] = true
+
+			if p := c.openerRules.check(opener, text); len(p) > 0 {
+				log.Printf("rejected opener %d/%d: %s", attempt, c.openerRules.Attempts, strings.Join(p, ", "))
+				if problems == nil {
+					problems = p
+				}
+				failed = append(failed, opener)
+				continue
+			}
+			passed = append(passed, opener)
+		}
+
+		if len(passed) > 0 {
+			ranked, judgeVersion := c.rankOpeners(ctx, notes, text, passed)
+			if judgeVersion != "" {
+				version += " " + judgeVersion
+			}
+			return ranked, version, nil
+		}
+
+		rejection = &openerRejection{Opener: failed[0], Problems: problems, Alternates: failed[1:]}
 	}
 
+	return nil, version, rejection
+}
+
+// cleanOpener fixes up the usual formatting mistakes of the model.
+func cleanOpener(res string) string {
 	// ai is dumb, force the string to be lowercase
 	res = strings.ToLower(res)
 
@@ -237,7 +337,7 @@ limit your response to 2 sentences total: cite specific events from the video an
 	// cut off trailing whitespace
 	res = strings.TrimSpace(res)
 
-	return res, nil
+	return res
 }
 
 // parse youtube channel id or handle from the youtube url
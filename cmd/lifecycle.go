@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	airtable "github.com/bjornpagen/airtable-go"
+)
+
+// Status is the lifecycle state of a lead, stored in the Status column.
+type Status string
+
+const (
+	StatusNew           Status = ""
+	StatusReadyName     Status = "ready-name"
+	StatusSuccessName   Status = "success-name"
+	StatusFailedName    Status = "failed-name"
+	StatusFailedForeign Status = "failed-foreign"
+	StatusReadyOpener   Status = "ready-opener"
+	StatusSuccessOpener Status = "success-opener"
+	StatusFailedOpener  Status = "failed-opener"
+	StatusNeedsReview   Status = "needs-review"
+	// StatusApprovedOpener and StatusRejectedOpener are decided in review.
+	StatusApprovedOpener Status = "approved-opener"
+	StatusRejectedOpener Status = "rejected-opener"
+)
+
+// statuses lists every declared state, in pipeline order.
+var statuses = []Status{
+	StatusNew,
+	StatusReadyName,
+	StatusSuccessName,
+	StatusFailedName,
+	StatusFailedForeign,
+	StatusReadyOpener,
+	StatusSuccessOpener,
+	StatusFailedOpener,
+	StatusNeedsReview,
+	StatusApprovedOpener,
+	StatusRejectedOpener,
+}
+
+// transitions holds the allowed next states for every state. Moves that
+// only happen by hand in Airtable (e.g. success-name -> ready-opener) are
+// declared too, so they validate when a command does them.
+var transitions = map[Status][]Status{
+	StatusNew:           {StatusReadyName},
+	StatusReadyName:     {StatusSuccessName, StatusFailedName, StatusFailedForeign},
+	StatusSuccessName:   {StatusReadyOpener},
+	StatusFailedName:    {StatusReadyName},
+	StatusFailedForeign: {},
+	StatusReadyOpener:   {StatusSuccessOpener, StatusFailedOpener, StatusNeedsReview},
+	// refresh-openers moves leads with a stale opener back to
+	// success-opener or needs-review
+	StatusSuccessOpener: {StatusApprovedOpener, StatusRejectedOpener, StatusSuccessOpener, StatusNeedsReview},
+	StatusFailedOpener:  {StatusReadyOpener},
+	// a human approves, regenerates or rejects the opener
+	StatusNeedsReview:    {StatusSuccessOpener, StatusReadyOpener, StatusFailedOpener, StatusApprovedOpener, StatusRejectedOpener, StatusNeedsReview},
+	StatusApprovedOpener: {StatusNeedsReview},
+	StatusRejectedOpener: {StatusReadyOpener},
+}
+
+func (s Status) String() string {
+	if s == StatusNew {
+		return "(none)"
+	}
+	return string(s)
+}
+
+// Valid reports whether s is a declared state.
+func (s Status) Valid() bool {
+	_, ok := transitions[s]
+	return ok
+}
+
+// CanTransition reports whether a lead may move from s to next.
+func (s Status) CanTransition(next Status) bool {
+	for _, to := range transitions[s] {
+		if to == next {
+			return true
+		}
+	}
+	return false
+}
+
+func validateTransition(from, to Status) error {
+	if !from.Valid() {
+		return fmt.Errorf("unknown status %q", from)
+	}
+	if !to.Valid() {
+		return fmt.Errorf("unknown status %q", to)
+	}
+	if !from.CanTransition(to) {
+		return fmt.Errorf("status transition %s -> %s is not allowed", from, to)
+	}
+	return nil
+}
+
+// statusIndex maps record IDs to their current status.
+func statusIndex(records []airtable.Record[Lead]) map[string]Status {
+	index := make(map[string]Status, len(records))
+	for _, rec := range records {
+		index[rec.ID] = rec.Fields.Status
+	}
+	return index
+}
+
+// updateLeads validates the status transition of every record against the
+// status it had in from, then writes the valid ones to the lead store.
+// Invalid records are logged, skipped and returned, so callers can keep
+// them. Records that don't set a Status are written as is. The write isn't
+// tied to the command's context, so results computed before an interrupt
+// still reach the store.
+func (c *Client) updateLeads(from map[string]Status, records []airtable.Record[Lead]) ([]airtable.Record[Lead], error) {
+	var valid, skipped []airtable.Record[Lead]
+	for _, rec := range records {
+		if rec.Fields.Status != StatusNew {
+			if err := validateTransition(from[rec.ID], rec.Fields.Status); err != nil {
+				log.Printf("skipping update of lead %s: %s", rec.ID, err.Error())
+				skipped = append(skipped, rec)
+				continue
+			}
+		}
+		valid = append(valid, rec)
+	}
+
+	_, err := retry(context.Background(), c.retry, "update leads", func(ctx context.Context) ([]airtable.Record[Lead], error) {
+		return callWithContext(ctx, func() ([]airtable.Record[Lead], error) {
+			return c.leadDb.Update(valid)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return skipped, nil
+}
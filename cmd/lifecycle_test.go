@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestValidateTransition(t *testing.T) {
+	tests := []struct {
+		from, to Status
+		ok       bool
+	}{
+		{StatusNew, StatusReadyName, true},
+		{StatusReadyName, StatusSuccessName, true},
+		{StatusReadyName, StatusFailedForeign, true},
+		{StatusReadyOpener, StatusNeedsReview, true},
+		{StatusSuccessOpener, StatusSuccessOpener, true},
+		{StatusNeedsReview, StatusApprovedOpener, true},
+		{StatusApprovedOpener, StatusNeedsReview, true},
+		{StatusRejectedOpener, StatusReadyOpener, true},
+		{StatusNew, StatusSuccessOpener, false},
+		{StatusFailedForeign, StatusReadyName, false},
+		// a refresh must not overwrite an approved opener
+		{StatusApprovedOpener, StatusSuccessOpener, false},
+		{Status("contacted"), StatusReadyOpener, false},
+		{StatusReadyOpener, Status("contacted"), false},
+	}
+	for _, tt := range tests {
+		err := validateTransition(tt.from, tt.to)
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("validateTransition(%s, %s) = %v, want ok %t", tt.from, tt.to, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// LLM completes chat requests. Every provider speaks the OpenAI chat API,
+// so requests and responses use the go-openai types.
+type LLM interface {
+	CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error)
+}
+
+// LLM providers.
+const (
+	llmOpenAI = "openai"
+	// llmAzure is an Azure OpenAI resource. Models are deployment names.
+	llmAzure = "azure"
+	// llmLocal is any server with an OpenAI compatible API, e.g. a
+	// llama.cpp server or Ollama.
+	llmLocal = "local"
+)
+
+// LLMConfig selects the LLM provider and the model of every stage that
+// prompts it.
+type LLMConfig struct {
+	Provider string `toml:"provider"`
+	// BaseURL is the Azure endpoint or the URL of the local server,
+	// e.g. "http://localhost:11434/v1". Empty uses the OpenAI API.
+	BaseURL string `toml:"base_url"`
+	// APIVersion is the Azure OpenAI API version, empty for the default.
+	APIVersion string `toml:"api_version"`
+
+	// NameModel infers names, OpenerModel writes openers and JudgeModel
+	// scores them. SummaryModel summarizes the parts of long transcripts.
+	NameModel    string `toml:"name_model"`
+	OpenerModel  string `toml:"opener_model"`
+	JudgeModel   string `toml:"judge_model"`
+	SummaryModel string `toml:"summary_model"`
+
+	// ContextTokens overrides the context window of models by name, see
+	// defaultContextTokens. AnswerTokens of it are kept for the answer,
+	// prompts longer than the rest aren't sent.
+	ContextTokens map[string]int `toml:"context_tokens"`
+	AnswerTokens  int            `toml:"answer_tokens"`
+}
+
+// stageJudge scores opener candidates. Unlike the other stages it never
+// fails a lead.
+const stageJudge = "judge"
+
+// stageSummary summarizes transcript chunks for the opener stage, and fails
+// leads as part of it.
+const stageSummary = "summary"
+
+func defaultLLMConfig() LLMConfig {
+	return LLMConfig{
+		Provider:     llmOpenAI,
+		NameModel:    openai.GPT3Dot5Turbo,
+		OpenerModel:  openai.GPT3Dot5Turbo,
+		JudgeModel:   openai.GPT3Dot5Turbo,
+		SummaryModel: openai.GPT3Dot5Turbo,
+		AnswerTokens: defaultAnswerTokens,
+	}
+}
+
+func (cfg LLMConfig) withDefaults() LLMConfig {
+	d := defaultLLMConfig()
+	if cfg.Provider == "" {
+		cfg.Provider = d.Provider
+	}
+	if cfg.NameModel == "" {
+		cfg.NameModel = d.NameModel
+	}
+	if cfg.OpenerModel == "" {
+		cfg.OpenerModel = d.OpenerModel
+	}
+	if cfg.JudgeModel == "" {
+		cfg.JudgeModel = d.JudgeModel
+	}
+	if cfg.SummaryModel == "" {
+		cfg.SummaryModel = d.SummaryModel
+	}
+	if cfg.AnswerTokens == 0 {
+		cfg.AnswerTokens = d.AnswerTokens
+	}
+	return cfg
+}
+
+func (cfg LLMConfig) validate() error {
+	if cfg.AnswerTokens < 0 {
+		return fmt.Errorf("answer_tokens must not be negative, got %d", cfg.AnswerTokens)
+	}
+	answer := cfg.AnswerTokens
+	if answer == 0 {
+		answer = defaultAnswerTokens
+	}
+	for model, n := range cfg.ContextTokens {
+		if n <= answer {
+			return fmt.Errorf("context_tokens of %s must be above answer_tokens, got %d", model, n)
+		}
+	}
+
+	switch cfg.Provider {
+	case "", llmOpenAI:
+	case llmAzure, llmLocal:
+		if cfg.BaseURL == "" {
+			return fmt.Errorf("provider %q needs a base_url", cfg.Provider)
+		}
+	default:
+		return fmt.Errorf("unknown provider %q (known: %s, %s, %s)", cfg.Provider, llmOpenAI, llmAzure, llmLocal)
+	}
+	return nil
+}
+
+// model returns the model prompted in stage.
+func (cfg LLMConfig) model(stage string) string {
+	switch stage {
+	case stageName:
+		return cfg.NameModel
+	case stageJudge:
+		return cfg.JudgeModel
+	case stageSummary:
+		return cfg.SummaryModel
+	default:
+		return cfg.OpenerModel
+	}
+}
+
+// newLLM creates the client of the configured provider. key may be empty
+// for local servers.
+func newLLM(cfg LLMConfig, key string) (LLM, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	switch cfg.Provider {
+	case llmAzure:
+		config := openai.DefaultAzureConfig(key, cfg.BaseURL)
+		if cfg.APIVersion != "" {
+			config.APIVersion = cfg.APIVersion
+		}
+		return openai.NewClientWithConfig(config), nil
+	case llmLocal:
+		config := openai.DefaultConfig(key)
+		config.BaseURL = cfg.BaseURL
+		return openai.NewClientWithConfig(config), nil
+	default:
+		config := openai.DefaultConfig(key)
+		if cfg.BaseURL != "" {
+			config.BaseURL = cfg.BaseURL
+		}
+		return openai.NewClientWithConfig(config), nil
+	}
+}
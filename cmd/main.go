@@ -1,62 +1,111 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
-	openai "github.com/sashabaranov/go-openai"
 	"github.com/spf13/cobra"
 	"go.uber.org/ratelimit"
 
 	airtable "github.com/bjornpagen/airtable-go"
-	prospety "github.com/bjornpagen/prospety-go"
-	mediadownloader "github.com/bjornpagen/youtube-apis/mediadownloader"
-	transcriptor "github.com/bjornpagen/youtube-apis/transcriptor"
 )
 
 var (
-	_prospetyKey        string
-	_airtableKey        string
-	_openaiKey          string
-	_transcriptorKey    string
-	_mediadownloaderKey string
+	_configPath    string
+	_secretsPath   string
+	_storeBackend  string
+	_storePath     string
+	_assignee      string
+	_dryRun        bool
+	_dryRunOut     string
+	_concurrency   int
+	_retryAttempts int
+	_callTimeout   time.Duration
+	_journalPath   string
+	_outputMode    string
+	_promptsDir    string
+	_llm           = defaultLLMConfig()
+	_fixturesPath  string
+	_openerRules   = defaultOpenerRules()
+	_chunkTokens   int
+	_videoPolicy   = defaultVideoPolicy()
+	_cacheConfig   = defaultCacheConfig()
+	_rateLimits    RateLimits
+
+	_airtableConfig    = defaultAirtableConfig()
+	_configCredentials map[string]string
 )
 
 func init() {
-	_prospetyKey = os.Getenv("PROSPETY_KEY")
-	_airtableKey = os.Getenv("AIRTABLE_KEY")
-	_openaiKey = os.Getenv("OPENAI_KEY")
-	_transcriptorKey = os.Getenv("TRANSCRIPTOR_KEY")
-	_mediadownloaderKey = os.Getenv("MEDIADOWNLOADER_KEY")
-
-	if _prospetyKey == "" {
-		log.Fatal("PROSPETY_KEY is required")
-	}
-	if _airtableKey == "" {
-		log.Fatal("AIRTABLE_KEY is required")
-	}
-	if _openaiKey == "" {
-		log.Fatal("OPENAI_KEY is required")
-	}
-	if _transcriptorKey == "" {
-		log.Fatal("TRANSCRIPTOR_KEY is required")
-	}
-	if _mediadownloaderKey == "" {
-		log.Fatal("MEDIADOWNLOADER_KEY is required")
-	}
+	// Global flags
+	rootCmd.PersistentFlags().StringVar(&_configPath, "config", defaultConfigPath, "path of the TOML config file")
+	rootCmd.PersistentFlags().StringVar(&_secretsPath, "secrets", defaultSecretsPath, "path of a KEY=VALUE file with API keys")
+	rootCmd.PersistentFlags().StringVar(&_storeBackend, "store", storeAirtable, "lead storage backend (airtable or file)")
+	rootCmd.PersistentFlags().StringVar(&_storePath, "store-path", "leads.json", "path of the lead file when --store=file")
+	rootCmd.PersistentFlags().StringVar(&_journalPath, "journal", defaultJournalPath, "checkpoint journal of unwritten results, empty to disable")
+	rootCmd.PersistentFlags().StringVar(&_outputMode, "structured-output", outputTools, "how structured GPT answers are requested: tools, json or text")
+	rootCmd.PersistentFlags().StringVar(&_promptsDir, "prompts", "", "directory of prompt templates replacing the built-in ones")
+	rootCmd.PersistentFlags().StringVar(&_llm.Provider, "llm", _llm.Provider, "LLM provider: openai, azure or local (any OpenAI compatible server)")
+	rootCmd.PersistentFlags().StringVar(&_llm.BaseURL, "llm-url", "", "Azure endpoint or local server URL of the LLM provider")
+	rootCmd.PersistentFlags().StringVar(&_llm.NameModel, "name-model", _llm.NameModel, "model used to infer names")
+	rootCmd.PersistentFlags().StringVar(&_llm.OpenerModel, "opener-model", _llm.OpenerModel, "model used to write openers")
+	rootCmd.PersistentFlags().StringVar(&_fixturesPath, "fixtures", "", "serve every external API from this fixtures file instead of the network (needs --store=file)")
+	rootCmd.PersistentFlags().IntVar(&_openerRules.Attempts, "opener-attempts", _openerRules.Attempts, "tries of an opener failing the checks before the lead needs review")
+	rootCmd.PersistentFlags().StringVar(&_llm.JudgeModel, "judge-model", _llm.JudgeModel, "model used to score opener candidates")
+	rootCmd.PersistentFlags().StringVar(&_llm.SummaryModel, "summary-model", _llm.SummaryModel, "model used to summarize the parts of long transcripts")
+	rootCmd.PersistentFlags().IntVar(&_chunkTokens, "chunk-tokens", defaultChunkTokens, "tokens of transcript per summarized part; shorter transcripts are used whole")
+	rootCmd.PersistentFlags().IntVar(&_openerRules.Candidates, "opener-candidates", _openerRules.Candidates, "openers generated per lead, the best one is kept")
+	rootCmd.PersistentFlags().BoolVar(&_videoPolicy.SkipShorts, "skip-shorts", _videoPolicy.SkipShorts, "don't write openers about Shorts")
+	rootCmd.PersistentFlags().BoolVar(&_videoPolicy.SkipLive, "skip-live", _videoPolicy.SkipLive, "don't write openers about livestreams, their replays and premieres")
+	rootCmd.PersistentFlags().DurationVar(&_videoPolicy.MinLength, "min-video-length", _videoPolicy.MinLength, "skip videos shorter than this, 0 for any length")
+	rootCmd.PersistentFlags().DurationVar(&_videoPolicy.MaxAge, "max-video-age", _videoPolicy.MaxAge, "skip videos published longer ago than this, 0 for any age")
+	rootCmd.PersistentFlags().DurationVar(&_videoPolicy.PopularWindow, "popular-window", _videoPolicy.PopularWindow, "prefer the most viewed video published within this window (e.g. 720h), 0 for the newest; needs an opener prefix that doesn't say \"latest\"")
+	rootCmd.PersistentFlags().IntVar(&_videoPolicy.Candidates, "video-candidates", _videoPolicy.Candidates, "videos tried per lead until one has a transcript")
+	rootCmd.PersistentFlags().StringVar(&_cacheConfig.Dir, "cache-dir", _cacheConfig.Dir, "directory caching channel videos, transcripts and LLM answers, empty to disable")
+	rootCmd.PersistentFlags().BoolVar(&_dryRun, "dry-run", false, "don't write to the lead store, print the planned writes instead")
+	rootCmd.PersistentFlags().StringVar(&_dryRunOut, "dry-run-out", "", "write the --dry-run plan as JSON to this file instead of printing it")
+	rootCmd.PersistentFlags().IntVar(&_concurrency, "concurrency", defaultConcurrency, "number of leads processed at once")
+	rootCmd.PersistentFlags().IntVar(&_retryAttempts, "retry-attempts", defaultRetryAttempts, "tries of every external call before a lead fails")
+	rootCmd.PersistentFlags().DurationVar(&_callTimeout, "call-timeout", defaultCallTimeout, "timeout of every single external call")
+	rootCmd.PersistentFlags().IntVar(&_rateLimits.OpenAI, "openai-rpm", defaultRateLimits().OpenAI, "OpenAI requests per minute")
+	rootCmd.PersistentFlags().IntVar(&_rateLimits.Airtable, "airtable-rpm", defaultRateLimits().Airtable, "Airtable requests per minute")
+	rootCmd.PersistentFlags().IntVar(&_rateLimits.Transcriptor, "transcriptor-rpm", defaultRateLimits().Transcriptor, "transcriptor requests per minute")
+	rootCmd.PersistentFlags().IntVar(&_rateLimits.Mediadownloader, "mediadownloader-rpm", defaultRateLimits().Mediadownloader, "mediadownloader requests per minute")
+	rootCmd.PersistentFlags().StringVar(&_assignee, "assignee", string(assigneeAny), `only work on leads assigned to this name or email, "any" or "unassigned"`)
 
 	// Add subcommands
 	rootCmd.AddCommand(mergeCmd)
 	rootCmd.AddCommand(genOpeners)
 	rootCmd.AddCommand(genName)
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(requeueCmd)
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configValidateCmd)
+	rootCmd.AddCommand(promptsCmd)
+	promptsCmd.AddCommand(promptsListCmd)
+	promptsCmd.AddCommand(promptsShowCmd)
+	rootCmd.AddCommand(reviewCmd)
+	rootCmd.AddCommand(refreshOpenersCmd)
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheInfoCmd)
+	cacheCmd.AddCommand(cachePurgeCmd)
 }
 
 var (
 	rootCmd = &cobra.Command{
 		Use:   "main",
 		Short: "A CLI tool to manage leads and activities",
+
+		PersistentPreRunE: loadConfig,
+
+		// main logs the error, don't print it twice with the usage
+		SilenceErrors: true,
+		SilenceUsage:  true,
 	}
 
 	mergeCmd = &cobra.Command{
@@ -76,59 +125,569 @@ var (
 		Short: "Generate an email-friendly name for all leads that don't have one",
 		Run:   runGenName,
 	}
+
+	statusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Print the number of leads in each status",
+		Run:   runStatus,
+	}
+
+	requeueCmd = &cobra.Command{
+		Use:   "requeue",
+		Short: "Move failed leads back to their ready status",
+		Run:   runRequeue,
+	}
+
+	configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the config file",
+	}
+
+	configValidateCmd = &cobra.Command{
+		Use:   "validate",
+		Short: "Check the config file for errors",
+		Run:   runConfigValidate,
+	}
+
+	promptsCmd = &cobra.Command{
+		Use:   "prompts",
+		Short: "Inspect the prompt templates",
+	}
+
+	promptsListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the prompt templates with their versions",
+		Args:  cobra.NoArgs,
+		Run:   runPromptsList,
+	}
+
+	promptsShowCmd = &cobra.Command{
+		Use:   "show PROMPT...",
+		Short: "Print prompt templates",
+		Args:  cobra.MinimumNArgs(1),
+		Run:   runPromptsShow,
+	}
+
+	reviewCmd = &cobra.Command{
+		Use:   "review",
+		Short: "Approve, edit, regenerate or reject the generated openers one by one",
+		Args:  cobra.NoArgs,
+		Run:   runReview,
+	}
+
+	refreshOpenersCmd = &cobra.Command{
+		Use:   "refresh-openers",
+		Short: "Regenerate the openers of uncontacted leads whose creator posted a newer video",
+		Args:  cobra.NoArgs,
+		Run:   runRefreshOpeners,
+	}
+
+	cacheCmd = &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect and purge the cache of channel videos, transcripts and LLM answers",
+	}
+
+	cacheInfoCmd = &cobra.Command{
+		Use:       "info [videos|transcripts|llm]...",
+		Short:     "Print the entries, expired entries and size of each cache",
+		ValidArgs: cacheKinds,
+		Run:       runCacheInfo,
+	}
+
+	cachePurgeCmd = &cobra.Command{
+		Use:       "purge [videos|transcripts|llm]...",
+		Short:     "Delete the entries of the given caches, or of all of them",
+		ValidArgs: cacheKinds,
+		Run:       runCachePurge,
+	}
 )
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	// the first interrupt stops handing out new work and lets finished
+	// results be written, the second one kills the process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+		log.Printf("interrupted, writing finished results; interrupt again to abort")
+	}()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		log.Fatal(err)
 	}
 }
 
 type Client struct {
-	pc *prospety.Client
-	db *airtable.Client
-	oc *openai.Client
-	tr *transcriptor.Client
-	md *mediadownloader.Client
+	pc  ProspectAPI
+	db  *airtable.Client
+	llm LLM
+	tr  TranscriptAPI
+	md  VideoAPI
 
 	gptLimiter ratelimit.Limiter
+	llmConfig  LLMConfig
 
-	leadDb     *airtable.Table[Lead]
+	leadDb     LeadStore
 	activityDb *airtable.Table[Activity]
+
+	assignee    assigneeFilter
+	concurrency int
+	retry       retryPolicy
+
+	dryRun    *dryRunLeadStore
+	dryRunOut string
+
+	journalPath string
+	journal     *journal
+
+	outputMode string
+	prompts    promptSet
+
+	openerRules OpenerRules
+	chunkTokens int
+	videoPolicy VideoPolicy
+
+	cache *diskCache
 }
 
-func New(prospetyKey, airtableKey, openaiKey, transcriptorKey, mediadownloaderKey string) (*Client, error) {
-	pc, err := prospety.New(prospetyKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create prospety client: %w", err)
+type Option func(option *options) error
+
+type options struct {
+	storeBackend string
+	storePath    string
+	assignee     assigneeFilter
+	airtable     *AirtableConfig
+	dryRun       bool
+	dryRunOut    string
+	concurrency  int
+	rateLimits   RateLimits
+	retry        retryPolicy
+	journalPath  string
+	outputMode   string
+	promptsDir   string
+	llm          LLMConfig
+	fixtures     *fixtures
+	openerRules  *OpenerRules
+	chunkTokens  int
+	videoPolicy  *VideoPolicy
+	cache        *CacheConfig
+}
+
+// WithLeadStore selects the lead storage backend, see openLeadStore.
+func WithLeadStore(backend, path string) Option {
+	return func(option *options) error {
+		option.storeBackend = backend
+		option.storePath = path
+		return nil
+	}
+}
+
+// WithAssignee restricts the commands to leads matching assignee, see
+// parseAssignee.
+func WithAssignee(assignee string) Option {
+	return func(option *options) error {
+		option.assignee = parseAssignee(assignee)
+		return nil
+	}
+}
+
+// WithAirtable sets the Airtable base, tables and lead column names.
+func WithAirtable(cfg AirtableConfig) Option {
+	return func(option *options) error {
+		option.airtable = &cfg
+		return nil
 	}
+}
+
+// WithDryRun records writes to the lead store instead of sending them.
+// Close reports them as text, or as JSON to out if it isn't empty.
+func WithDryRun(out string) Option {
+	return func(option *options) error {
+		option.dryRun = true
+		option.dryRunOut = out
+		return nil
+	}
+}
+
+// WithConcurrency sets the number of leads processed at once.
+func WithConcurrency(n int) Option {
+	return func(option *options) error {
+		if n < 1 {
+			return fmt.Errorf("concurrency must be at least 1, got %d", n)
+		}
+		option.concurrency = n
+		return nil
+	}
+}
+
+// WithRateLimits sets the request rate of every upstream API. Zero rates
+// keep their default.
+func WithRateLimits(r RateLimits) Option {
+	return func(option *options) error {
+		for _, l := range []struct {
+			api string
+			rpm int
+		}{
+			{"openai", r.OpenAI},
+			{"airtable", r.Airtable},
+			{"transcriptor", r.Transcriptor},
+			{"mediadownloader", r.Mediadownloader},
+		} {
+			if l.rpm < 0 {
+				return fmt.Errorf("%s rate limit must not be negative, got %d", l.api, l.rpm)
+			}
+		}
+		option.rateLimits = r
+		return nil
+	}
+}
 
-	db, err := airtable.New(airtableKey)
+// WithRetryAttempts sets how many times every external call is tried
+// before it fails.
+func WithRetryAttempts(n int) Option {
+	return func(option *options) error {
+		if n < 1 {
+			return fmt.Errorf("retry attempts must be at least 1, got %d", n)
+		}
+		option.retry.Attempts = n
+		return nil
+	}
+}
+
+// WithCallTimeout sets how long a single external call may take.
+func WithCallTimeout(d time.Duration) Option {
+	return func(option *options) error {
+		if d <= 0 {
+			return fmt.Errorf("call timeout must be positive, got %s", d)
+		}
+		option.retry.Timeout = d
+		return nil
+	}
+}
+
+// WithJournal journals generated results at path until they are written,
+// so a later run can replay them after a crash. It is ignored in dry run
+// mode.
+func WithJournal(path string) Option {
+	return func(option *options) error {
+		option.journalPath = path
+		return nil
+	}
+}
+
+// WithStructuredOutput sets how structured GPT answers are requested, see
+// gptStructured.
+func WithStructuredOutput(mode string) Option {
+	return func(option *options) error {
+		if !validOutputMode(mode) {
+			return fmt.Errorf("unknown structured output mode %q", mode)
+		}
+		option.outputMode = mode
+		return nil
+	}
+}
+
+// WithPrompts replaces the built-in prompt templates with the ones in dir,
+// see loadPrompts.
+func WithPrompts(dir string) Option {
+	return func(option *options) error {
+		option.promptsDir = dir
+		return nil
+	}
+}
+
+// WithLLM selects the LLM provider and the model of every stage.
+func WithLLM(cfg LLMConfig) Option {
+	return func(option *options) error {
+		if err := cfg.validate(); err != nil {
+			return err
+		}
+		option.llm = cfg
+		return nil
+	}
+}
+
+// WithFixtures replaces every external API but the lead store with fakes
+// serving fx, see fixtures. No keys are needed.
+func WithFixtures(fx *fixtures) Option {
+	return func(option *options) error {
+		option.fixtures = fx
+		return nil
+	}
+}
+
+// WithOpenerRules sets the checks generated openers must pass.
+func WithOpenerRules(r OpenerRules) Option {
+	return func(option *options) error {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("bad opener rules: %w", err)
+		}
+		option.openerRules = &r
+		return nil
+	}
+}
+
+// WithChunkTokens sets the size of the transcript parts that are
+// summarized when a transcript is too long to use whole.
+func WithChunkTokens(n int) Option {
+	return func(option *options) error {
+		if n < 1 {
+			return fmt.Errorf("chunk tokens must be at least 1, got %d", n)
+		}
+		option.chunkTokens = n
+		return nil
+	}
+}
+
+// WithVideoPolicy sets which videos openers are written about.
+func WithVideoPolicy(p VideoPolicy) Option {
+	return func(option *options) error {
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("bad video policy: %w", err)
+		}
+		option.videoPolicy = &p
+		return nil
+	}
+}
+
+// WithCache caches channel videos, transcripts and LLM answers in
+// cfg.Dir. An empty Dir caches nothing.
+func WithCache(cfg CacheConfig) Option {
+	return func(option *options) error {
+		if err := cfg.validate(); err != nil {
+			return fmt.Errorf("bad cache: %w", err)
+		}
+		option.cache = &cfg
+		return nil
+	}
+}
+
+// newClient creates a Client from the global flags, with only the API
+// clients behind keys. The Airtable key is added when the lead store needs
+// it.
+func newClient(cmd *cobra.Command, keys ...string) (*Client, error) {
+	// only commands calling the cached APIs get the cache
+	cacheable := false
+	for _, key := range keys {
+		switch key {
+		case keyOpenAI, keyTranscriptor, keyMediadownloader:
+			cacheable = true
+		}
+	}
+
+	var fx *fixtures
+	if _fixturesPath != "" {
+		if _storeBackend != storeFile {
+			return nil, fmt.Errorf("--fixtures runs without network, it needs --store=%s", storeFile)
+		}
+
+		var err error
+		fx, err = readFixtures(_fixturesPath)
+		if err != nil {
+			return nil, err
+		}
+		keys = nil
+	}
+
+	if _storeBackend == storeAirtable {
+		keys = append(keys, keyAirtable)
+	}
+
+	// local servers usually don't need a key, use it only if there is one
+	localLLM := false
+	if _llm.Provider == llmLocal {
+		for i, key := range keys {
+			if key == keyOpenAI {
+				keys = append(keys[:i:i], keys[i+1:]...)
+				localLLM = true
+				break
+			}
+		}
+	}
+
+	creds, err := resolveCredentials(cmd, keys)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create airtable client: %w", err)
+		return nil, err
+	}
+
+	if localLLM {
+		if local, err := resolveCredentials(cmd, []string{keyOpenAI}); err == nil {
+			creds[keyOpenAI] = local[keyOpenAI]
+		}
+	}
+
+	opts := []Option{
+		WithLeadStore(_storeBackend, _storePath),
+		WithAssignee(_assignee),
+		WithAirtable(_airtableConfig),
+		WithConcurrency(_concurrency),
+		WithRateLimits(_rateLimits),
+		WithRetryAttempts(_retryAttempts),
+		WithCallTimeout(_callTimeout),
+		WithJournal(_journalPath),
+		WithStructuredOutput(_outputMode),
+		WithPrompts(_promptsDir),
+		WithLLM(_llm),
+		WithOpenerRules(_openerRules),
+		WithChunkTokens(_chunkTokens),
+		WithVideoPolicy(_videoPolicy),
+	}
+	if fx != nil {
+		opts = append(opts, WithFixtures(fx))
+	} else if cacheable {
+		// fixture runs must see the fixtures, not answers of earlier runs
+		opts = append(opts, WithCache(_cacheConfig))
+	}
+	if _dryRun || _dryRunOut != "" {
+		opts = append(opts, WithDryRun(_dryRunOut))
+	}
+
+	return New(creds[keyProspety], creds[keyAirtable], creds[keyOpenAI], creds[keyTranscriptor], creds[keyMediadownloader], opts...)
+}
+
+// New creates a Client. Empty keys leave the matching API client nil, so
+// callers only need the keys of the APIs they use.
+func New(prospetyKey, airtableKey, openaiKey, transcriptorKey, mediadownloaderKey string, opts ...Option) (*Client, error) {
+	o := &options{retry: defaultRetryPolicy()}
+	for _, opt := range opts {
+		err := opt(o)
+		if err != nil {
+			return nil, fmt.Errorf("bad option: %w", err)
+		}
+	}
+
+	if o.storeBackend == "" {
+		o.storeBackend = storeAirtable
+	}
+
+	if o.assignee == "" {
+		o.assignee = assigneeAny
+	}
+
+	if o.outputMode == "" {
+		o.outputMode = outputTools
 	}
 
-	tr, err := transcriptor.New(transcriptorKey)
+	o.llm = o.llm.withDefaults()
+
+	if o.openerRules == nil {
+		o.openerRules = new(OpenerRules)
+		*o.openerRules = defaultOpenerRules()
+	}
+
+	if o.videoPolicy == nil {
+		o.videoPolicy = new(VideoPolicy)
+		*o.videoPolicy = defaultVideoPolicy()
+	}
+
+	if err := o.videoPolicy.checkClaims(*o.openerRules); err != nil {
+		return nil, fmt.Errorf("bad video policy: %w", err)
+	}
+
+	if o.concurrency == 0 {
+		o.concurrency = defaultConcurrency
+	}
+
+	if o.chunkTokens == 0 {
+		o.chunkTokens = defaultChunkTokens
+	}
+
+	o.rateLimits = o.rateLimits.withDefaults()
+
+	if o.airtable == nil {
+		o.airtable = new(AirtableConfig)
+		*o.airtable = defaultAirtableConfig()
+	}
+
+	if err := useLeadColumns(o.airtable.LeadFields); err != nil {
+		return nil, fmt.Errorf("bad lead fields: %w", err)
+	}
+
+	c := &Client{
+		gptLimiter:  perMinute(o.rateLimits.OpenAI),
+		llmConfig:   o.llm,
+		openerRules: *o.openerRules,
+		chunkTokens: o.chunkTokens,
+		videoPolicy: *o.videoPolicy,
+		assignee:    o.assignee,
+		concurrency: o.concurrency,
+		retry:       o.retry,
+		outputMode:  o.outputMode,
+	}
+
+	var err error
+	if o.cache != nil && o.cache.Dir != "" {
+		c.cache = newDiskCache(*o.cache)
+	}
+
+	c.prompts, err = loadPrompts(o.promptsDir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create transcriptor client: %w", err)
+		return nil, err
+	}
+
+	if prospetyKey != "" {
+		c.pc = prospetyAPI{key: prospetyKey}
+	}
+
+	if airtableKey != "" {
+		c.db, err = airtable.New(airtableKey, airtable.WithRateLimit(perMinute(o.rateLimits.Airtable)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create airtable client: %w", err)
+		}
+		c.activityDb = NewActivityDB(c.db, *o.airtable)
 	}
 
-	md, err := mediadownloader.New(mediadownloaderKey)
+	if openaiKey != "" || o.llm.Provider == llmLocal {
+		c.llm, err = newLLM(o.llm, openaiKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create LLM client: %w", err)
+		}
+	}
+
+	if transcriptorKey != "" {
+		c.tr = transcriptorAPI{key: transcriptorKey, limiter: perMinute(o.rateLimits.Transcriptor)}
+	}
+
+	if mediadownloaderKey != "" {
+		c.md = mediadownloaderAPI{key: mediadownloaderKey, limiter: perMinute(o.rateLimits.Mediadownloader)}
+	}
+
+	if o.fixtures != nil {
+		c.pc = fakeProspety{o.fixtures}
+		c.llm = newFakeLLM(o.fixtures)
+		c.tr = fakeTranscriptor{o.fixtures}
+		c.md = fakeMediadownloader{o.fixtures}
+	}
+
+	c.leadDb, err = openLeadStore(o.storeBackend, o.storePath, c.db, *o.airtable)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create mediadownloader client: %w", err)
+		return nil, fmt.Errorf("failed to open lead store: %w", err)
 	}
 
-	c := &Client{
-		pc:         pc,
-		db:         db,
-		oc:         openai.NewClient(openaiKey),
-		tr:         tr,
-		md:         md,
-		gptLimiter: ratelimit.New(30, ratelimit.Per(time.Minute)),
+	if o.dryRun {
+		c.dryRun = newDryRunLeadStore(c.leadDb)
+		c.dryRunOut = o.dryRunOut
+		c.leadDb = c.dryRun
 	}
 
-	c.leadDb = NewLeadDB(c.db)
-	c.activityDb = NewActivityDB(c.db)
+	if !o.dryRun {
+		// opened by replayJournal, so commands not writing results don't
+		// create it
+		c.journalPath = o.journalPath
+	}
 
 	return c, nil
 }
+
+// Close finishes a run, reporting the planned writes in dry run mode.
+func (c *Client) Close() error {
+	if c.journal != nil {
+		if err := c.journal.Close(); err != nil {
+			return err
+		}
+	}
+	if c.dryRun != nil {
+		return c.dryRun.report(c.dryRunOut)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 
@@ -9,18 +10,21 @@ import (
 )
 
 func runMerge(cmd *cobra.Command, args []string) {
-	c, err := New(_prospetyKey, _airtableKey, _openaiKey, _transcriptorKey, _mediadownloaderKey)
+	c, err := newClient(cmd, keyProspety)
 	if err != nil {
 		log.Fatal(err)
 	}
-	if err := c.mergeProspetyLeads(); err != nil {
+	if err := c.mergeProspetyLeads(cmd.Context()); err != nil {
+		log.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func (c *Client) mergeProspetyLeads() error {
+func (c *Client) mergeProspetyLeads(ctx context.Context) error {
 	// Get all the prospects
-	prospects, err := c.getProspects()
+	prospects, err := c.getProspects(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to merge: %w", err)
 	}
@@ -47,7 +51,7 @@ func (c *Client) mergeProspetyLeads() error {
 	leads = uniqueLeads
 
 	// fetch all airtable leads
-	upstreamLeads, err := c.getAirtableLeads()
+	upstreamLeads, err := c.getAirtableLeads(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get airtable leads: %w", err)
 	}
@@ -67,6 +71,13 @@ func (c *Client) mergeProspetyLeads() error {
 		}
 	}
 
+	// assign the new leads, if --assignee names a user airtable can write
+	if user := c.assignee.user(); user != nil {
+		for i := range newLeads {
+			newLeads[i].Assignee = user
+		}
+	}
+
 	// create it
 	res, err := c.leadDb.Create(newLeads)
 	if err != nil {
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/ratelimit"
+)
+
+// runPool calls fn for every item on n workers and waits for all of them.
+// Once ctx is done no new items are handed out, but the calls already
+// running are waited for.
+func runPool[T any](ctx context.Context, n int, items []T, fn func(item T)) {
+	if n < 1 {
+		n = 1
+	}
+
+	work := make(chan T)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range work {
+				fn(item)
+			}
+		}()
+	}
+
+dispatch:
+	for _, item := range items {
+		select {
+		case work <- item:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(work)
+
+	wg.Wait()
+}
+
+// RateLimits are the request rates allowed per upstream API, in requests
+// per minute. Zero keeps the default.
+type RateLimits struct {
+	OpenAI          int `toml:"openai_rpm"`
+	Airtable        int `toml:"airtable_rpm"`
+	Transcriptor    int `toml:"transcriptor_rpm"`
+	Mediadownloader int `toml:"mediadownloader_rpm"`
+}
+
+const defaultConcurrency = 8
+
+// defaultRateLimits match the defaults of the API client libraries.
+func defaultRateLimits() RateLimits {
+	return RateLimits{
+		OpenAI:          30,
+		Airtable:        5 * 60,
+		Transcriptor:    9 * 60,
+		Mediadownloader: 2 * 60,
+	}
+}
+
+func (r RateLimits) withDefaults() RateLimits {
+	d := defaultRateLimits()
+	if r.OpenAI == 0 {
+		r.OpenAI = d.OpenAI
+	}
+	if r.Airtable == 0 {
+		r.Airtable = d.Airtable
+	}
+	if r.Transcriptor == 0 {
+		r.Transcriptor = d.Transcriptor
+	}
+	if r.Mediadownloader == 0 {
+		r.Mediadownloader = d.Mediadownloader
+	}
+	return r
+}
+
+func perMinute(rpm int) ratelimit.Limiter {
+	return ratelimit.New(rpm, ratelimit.Per(time.Minute))
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultPrompts are the prompt templates built into the binary. Files of
+// the same name in the --prompts directory replace them.
+//
+//go:embed prompts/*.tmpl
+var defaultPrompts embed.FS
+
+// The prompts, named after their template files without ".tmpl".
+const (
+	promptName        = "name"
+	promptOpenerNotes = "opener-notes"
+	promptOpener      = "opener"
+	promptOpenerJudge = "opener-judge"
+	// promptTranscriptChunk summarizes a part of a transcript too long
+	// for the opener-notes prompt.
+	promptTranscriptChunk = "transcript-chunk"
+)
+
+type namePromptData struct {
+	// Channel is the scraped channel as JSON.
+	Channel string
+}
+
+type openerNotesPromptData struct {
+	// Transcript is the whole transcript, or the summaries of its parts if
+	// Summarized, see transcriptNotesInput.
+	Transcript string
+	Summarized bool
+}
+
+type openerPromptData struct {
+	// Notes are the answers to the opener-notes prompt.
+	Notes string
+}
+
+// promptData holds the data type of every prompt. Templates are checked
+// against it when they are loaded, so a misspelled variable fails the run
+// before any lead is touched.
+var promptData = map[string]any{
+	promptName:        namePromptData{},
+	promptOpenerNotes: openerNotesPromptData{},
+	promptOpener:      openerPromptData{},
+	promptOpenerJudge: openerJudgePromptData{},
+
+	promptTranscriptChunk: transcriptChunkPromptData{},
+}
+
+type prompt struct {
+	Name string
+	// Source is the file the template was read from, or "built-in".
+	Source string
+	Text   string
+	// Version identifies the template text, e.g. "opener@1f2e3d4c".
+	Version string
+
+	tmpl *template.Template
+}
+
+type promptSet map[string]*prompt
+
+const promptSourceBuiltin = "built-in"
+
+// loadPrompts reads the built-in prompts, replacing them with the templates
+// in dir if it isn't empty.
+func loadPrompts(dir string) (promptSet, error) {
+	set := make(promptSet, len(promptData))
+
+	builtin, err := defaultPrompts.ReadDir("prompts")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read built-in prompts: %w", err)
+	}
+	for _, entry := range builtin {
+		text, err := defaultPrompts.ReadFile("prompts/" + entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read built-in prompt %s: %w", entry.Name(), err)
+		}
+		if err := set.add(strings.TrimSuffix(entry.Name(), ".tmpl"), promptSourceBuiltin, string(text)); err != nil {
+			return nil, err
+		}
+	}
+
+	if dir != "" {
+		paths, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list prompts: %w", err)
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no prompt templates (*.tmpl) in %s", dir)
+		}
+
+		for _, path := range paths {
+			text, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read prompt: %w", err)
+			}
+			if err := set.add(strings.TrimSuffix(filepath.Base(path), ".tmpl"), path, string(text)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return set, nil
+}
+
+func (s promptSet) add(name, source, text string) error {
+	data, ok := promptData[name]
+	if !ok {
+		return fmt.Errorf("unknown prompt %q in %s (known: %s)", name, source, strings.Join(sortedKeys(promptData), ", "))
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return fmt.Errorf("bad prompt %s: %w", source, err)
+	}
+	if err := tmpl.Execute(io.Discard, data); err != nil {
+		return fmt.Errorf("bad prompt %s: %w", source, err)
+	}
+
+	sum := sha256.Sum256([]byte(text))
+	s[name] = &prompt{
+		Name:    name,
+		Source:  source,
+		Text:    text,
+		Version: name + "@" + hex.EncodeToString(sum[:4]),
+		tmpl:    tmpl,
+	}
+	return nil
+}
+
+// render fills in the prompt name with data, returning the text and the
+// version of the template.
+func (s promptSet) render(name string, data any) (string, string, error) {
+	p, ok := s[name]
+	if !ok {
+		return "", "", fmt.Errorf("unknown prompt %q", name)
+	}
+
+	var b strings.Builder
+	if err := p.tmpl.Execute(&b, data); err != nil {
+		return "", "", fmt.Errorf("failed to render prompt %s: %w", name, err)
+	}
+
+	return b.String(), p.Version, nil
+}
+
+func runPromptsList(cmd *cobra.Command, args []string) {
+	set, err := loadPrompts(_promptsDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROMPT\tVERSION\tSOURCE")
+	for _, name := range sortedKeys(set) {
+		p := set[name]
+		fmt.Fprintf(w, "%s\t%s\t%s\n", p.Name, p.Version, p.Source)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runPromptsShow(cmd *cobra.Command, args []string) {
+	set, err := loadPrompts(_promptsDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, name := range args {
+		p, ok := set[name]
+		if !ok {
+			log.Fatalf("unknown prompt %q (known: %s)", name, strings.Join(sortedKeys(set), ", "))
+		}
+
+		fmt.Printf("# %s (%s)\n%s", p.Version, p.Source, p.Text)
+		if !strings.HasSuffix(p.Text, "\n") {
+			fmt.Println()
+		}
+	}
+}
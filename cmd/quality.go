@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// OpenerRules are the checks every generated opener must pass. An opener
+// failing them is regenerated up to Attempts times in total, then the lead
+// is marked needs-review with the last opener.
+type OpenerRules struct {
+	Attempts int `toml:"attempts"`
+	// Candidates is the number of openers generated per attempt. The ones
+	// passing the checks are ranked, see rankOpeners.
+	Candidates int `toml:"candidates"`
+	// Judge lets the LLM score the candidates too.
+	Judge bool `toml:"judge"`
+
+	// Prefix is how every opener must start, after lowercasing.
+	Prefix string `toml:"prefix"`
+	// MaxSentences counts the prefix as the start of the first sentence.
+	MaxSentences int `toml:"max_sentences"`
+	// MinLength and MaxLength are in characters.
+	MinLength int `toml:"min_length"`
+	MaxLength int `toml:"max_length"`
+	// BannedPhrases must not appear anywhere, case insensitively. They
+	// catch AI disclaimers and made up anecdotes.
+	BannedPhrases []string `toml:"banned_phrases"`
+	// RequireDetail demands a word from the transcript that isn't in the
+	// prefix, so the opener shows the video was watched.
+	RequireDetail bool `toml:"require_detail"`
+}
+
+func defaultOpenerRules() OpenerRules {
+	return OpenerRules{
+		Attempts:     3,
+		Candidates:   3,
+		Judge:        true,
+		Prefix:       "i loved your latest video! i",
+		MaxSentences: 2,
+		MinLength:    60,
+		MaxLength:    320,
+		BannedPhrases: []string{
+			"as an ai",
+			"language model",
+			"i'm an ai",
+			"i remember when",
+			"when i was",
+			"reminds me of when",
+			"my own experience",
+			"i once",
+		},
+		RequireDetail: true,
+	}
+}
+
+func (r OpenerRules) validate() error {
+	switch {
+	case r.Attempts < 1:
+		return fmt.Errorf("attempts must be at least 1, got %d", r.Attempts)
+	case r.Candidates < 1:
+		return fmt.Errorf("candidates must be at least 1, got %d", r.Candidates)
+	case r.MaxSentences < 0 || r.MinLength < 0 || r.MaxLength < 0:
+		return fmt.Errorf("max_sentences, min_length and max_length must not be negative")
+	case r.MaxLength > 0 && r.MinLength > r.MaxLength:
+		return fmt.Errorf("min_length %d is above max_length %d", r.MinLength, r.MaxLength)
+	}
+	return nil
+}
+
+// check returns every rule opener breaks, empty if it passes. Zero limits
+// aren't checked.
+func (r OpenerRules) check(opener, transcript string) []string {
+	var problems []string
+
+	if r.Prefix != "" && !strings.HasPrefix(opener, r.Prefix) {
+		problems = append(problems, fmt.Sprintf("doesn't start with %q", r.Prefix))
+	}
+
+	if r.MaxSentences > 0 {
+		if n := countSentences(strings.TrimPrefix(opener, r.Prefix)); n > r.MaxSentences {
+			problems = append(problems, fmt.Sprintf("has %d sentences, at most %d allowed", n, r.MaxSentences))
+		}
+	}
+
+	length := utf8.RuneCountInString(opener)
+	if r.MinLength > 0 && length < r.MinLength {
+		problems = append(problems, fmt.Sprintf("is %d characters, at least %d needed", length, r.MinLength))
+	}
+	if r.MaxLength > 0 && length > r.MaxLength {
+		problems = append(problems, fmt.Sprintf("is %d characters, at most %d allowed", length, r.MaxLength))
+	}
+
+	lower := strings.ToLower(opener)
+	for _, phrase := range r.BannedPhrases {
+		if phrase != "" && strings.Contains(lower, strings.ToLower(phrase)) {
+			problems = append(problems, fmt.Sprintf("contains %q", phrase))
+		}
+	}
+
+	if r.RequireDetail && mentionedDetails(strings.TrimPrefix(lower, r.Prefix), transcript) == 0 {
+		problems = append(problems, "mentions nothing from the transcript")
+	}
+
+	return problems
+}
+
+var sentenceEndRegexp = regexp.MustCompile(`[.!?]+(\s+|$)`)
+
+func countSentences(s string) int {
+	n := 0
+	for _, sentence := range sentenceEndRegexp.Split(s, -1) {
+		if strings.TrimSpace(sentence) != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// detailStopwords are words too common to prove anything was watched.
+var detailStopwords = map[string]bool{
+	"about": true, "after": true, "again": true, "also": true, "always": true,
+	"because": true, "been": true, "before": true, "best": true, "could": true,
+	"does": true, "doing": true, "done": true, "down": true, "each": true,
+	"even": true, "ever": true, "every": true, "favorite": true, "from": true,
+	"going": true, "good": true, "great": true, "have": true, "here": true,
+	"into": true, "just": true, "know": true, "like": true, "love": true,
+	"loved": true, "made": true, "make": true, "many": true, "more": true,
+	"most": true, "much": true, "only": true, "other": true, "over": true,
+	"part": true, "really": true, "right": true, "some": true, "that": true,
+	"their": true, "them": true, "then": true, "there": true, "these": true,
+	"they": true, "thing": true, "things": true, "think": true, "this": true,
+	"those": true, "time": true, "very": true, "video": true, "want": true,
+	"watch": true, "watching": true, "well": true, "what": true, "when": true,
+	"where": true, "which": true, "while": true, "with": true, "would": true,
+	"your": true, "yours": true,
+}
+
+// mentionedDetails counts the distinct meaningful words opener shares with
+// transcript.
+func mentionedDetails(opener, transcript string) int {
+	words := make(map[string]bool)
+	for _, w := range detailWords(transcript) {
+		words[w] = true
+	}
+
+	mentioned := make(map[string]bool)
+	for _, w := range detailWords(opener) {
+		if words[w] {
+			mentioned[w] = true
+		}
+	}
+	return len(mentioned)
+}
+
+func detailWords(s string) []string {
+	var words []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if utf8.RuneCountInString(w) >= 4 && !detailStopwords[w] {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// openerRejection is an opener that failed the checks on every attempt,
+// with the problems of the last candidate.
+type openerRejection struct {
+	Opener   string
+	Problems []string
+	// Alternates are the other candidates of the last attempt.
+	Alternates []string
+}
+
+func (e *openerRejection) Error() string {
+	return "opener " + strings.Join(e.Problems, ", ")
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOpenerRulesCheck(t *testing.T) {
+	const transcript = "today the kettlebell rolled off the mat, then we finished with hip hinge drills"
+	rules := defaultOpenerRules()
+
+	tests := []struct {
+		name   string
+		opener string
+		want   []string
+	}{
+		{
+			name:   "passes",
+			opener: "i loved your latest video! i laughed when the kettlebell rolled off the mat, and the hip hinge drill was my favorite part.",
+		},
+		{
+			name:   "wrong prefix",
+			opener: "your latest video was great! i laughed when the kettlebell rolled off the mat during the drills.",
+			want:   []string{`doesn't start with "i loved your latest video! i"`},
+		},
+		{
+			name:   "too many sentences",
+			opener: "i loved your latest video! i laughed at the kettlebell. the drills were fun. the ending was great.",
+			want:   []string{"has 3 sentences, at most 2 allowed"},
+		},
+		{
+			name:   "too short",
+			opener: "i loved your latest video! i like kettlebell drills.",
+			want:   []string{"is 52 characters, at least 60 needed"},
+		},
+		{
+			name:   "banned phrase",
+			opener: "i loved your latest video! i once dropped a kettlebell too, so the rolling one on the mat was my favorite.",
+			want:   []string{`contains "i once"`},
+		},
+		{
+			name:   "no detail",
+			opener: "i loved your latest video! i think you are so great and i really love everything that you do.",
+			want:   []string{"mentions nothing from the transcript"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules.check(tt.opener, transcript); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("check(%q) = %q, want %q", tt.opener, got, tt.want)
+			}
+		})
+	}
+}
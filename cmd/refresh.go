@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+
+	airtable "github.com/bjornpagen/airtable-go"
+	mediadownloader "github.com/bjornpagen/youtube-apis/mediadownloader"
+	transcriptor "github.com/bjornpagen/youtube-apis/transcriptor"
+	"github.com/spf13/cobra"
+)
+
+func runRefreshOpeners(cmd *cobra.Command, args []string) {
+	c, err := newClient(cmd, keyOpenAI, keyTranscriptor, keyMediadownloader)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := c.refreshOpeners(cmd.Context()); err != nil {
+		log.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// refreshStatuses are the statuses of leads that have an opener but weren't
+// contacted yet. Approved openers may have been edited by hand, so their
+// refresh is only a suggestion, see keepApproved.
+var refreshStatuses = map[Status]bool{
+	StatusSuccessOpener:  true,
+	StatusNeedsReview:    true,
+	StatusApprovedOpener: true,
+}
+
+// refreshOpeners regenerates the openers of uncontacted leads whose video
+// isn't the one gen-openers would pick anymore, because the creator posted
+// a newer one. Leads whose refresh fails keep their opener, approved leads
+// go back to review.
+func (c *Client) refreshOpeners(ctx context.Context) error {
+	// write results a previous run left behind first
+	if err := c.replayJournal(); err != nil {
+		return err
+	}
+
+	leads, err := callWithContext(ctx, func() ([]airtable.Record[Lead], error) {
+		return c.leadDb.List(func(lead *Lead) bool {
+			return refreshStatuses[lead.Status] && c.assignee.match(lead.Assignee)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get airtable leads: %w", err)
+	}
+
+	log.Printf("found %d leads to check for stale openers", len(leads))
+
+	w := c.newLeadWriter(statusIndex(leads))
+	var upToDate, refreshed, needsReview, reapprove, failures, unknown atomic.Int64
+	runPool(ctx, c.concurrency, leads, func(lead airtable.Record[Lead]) {
+		if lead.Fields.OpenerVideoID == "" {
+			// written before the video was recorded, nothing to compare
+			unknown.Add(1)
+			return
+		}
+
+		video, transcript, err := c.refreshSource(ctx, lead.Fields)
+		if err == nil && video == nil {
+			upToDate.Add(1)
+			return
+		}
+		if err == nil {
+			log.Printf("opener of lead %s is about %s, refreshing it with %s", lead.ID, lead.Fields.OpenerVideoID, video.ID)
+			var rec *airtable.Record[Lead]
+			rec, err = c.openerUpdate(ctx, lead.ID, lead.Fields, video, transcript)
+			if err == nil {
+				if lead.Fields.Status == StatusApprovedOpener {
+					keepApproved(rec.Fields, lead.Fields)
+					reapprove.Add(1)
+				} else if rec.Fields.Status == StatusNeedsReview {
+					needsReview.Add(1)
+				} else {
+					refreshed.Add(1)
+				}
+				w.add(*rec)
+				return
+			}
+		}
+		if ctx.Err() != nil {
+			// interrupted, leave the lead as it is
+			log.Printf("interrupted lead %s: %s", lead.ID, err.Error())
+			return
+		}
+
+		log.Printf("failed to refresh lead %s, keeping its opener: %s", lead.ID, err.Error())
+		failures.Add(1)
+	})
+
+	log.Printf("%d openers up to date", upToDate.Load())
+	log.Printf("%d openers refreshed", refreshed.Load())
+	log.Printf("%d refreshed openers need review", needsReview.Load())
+	log.Printf("%d approved openers refreshed and sent back to review", reapprove.Load())
+	log.Printf("%d openers failed to refresh", failures.Load())
+	log.Printf("%d openers without a recorded video", unknown.Load())
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to update airtable leads: %w", err)
+	}
+
+	return nil
+}
+
+// keepApproved turns the refresh of an approved opener into a suggestion:
+// the lead goes back to review keeping the approved opener, which may have
+// been edited by hand, with the refreshed openers as its alternates. The
+// video recorded is the new one the alternates are about, so the next
+// refresh leaves the lead alone. Nothing failed, so the failure fields of
+// a rejected refresh aren't written.
+func keepApproved(update, approved *Lead) {
+	var alternates []string
+	if update.Opener != "" {
+		alternates = append(alternates, string(update.Opener))
+	}
+	alternates = append(alternates, splitAlternates(string(update.OpenerAlternates))...)
+
+	update.Opener = approved.Opener
+	update.OpenerAlternates = airtable.LongText(strings.Join(alternates, "\n"))
+	update.OpenerPrompt = ""
+	update.Status = StatusNeedsReview
+
+	update.FailureStage, update.FailureClass, update.FailureMessage, update.FailedAt = "", "", "", ""
+	update.Attempts = 0
+	update.ClearFailure = false
+}
+
+// refreshSource returns the video a new opener for lead should be written
+// about and its transcript, or a nil video if the recorded one is still the
+// one gen-openers would pick: newer candidates without a transcript are
+// skipped the same way.
+func (c *Client) refreshSource(ctx context.Context, lead *Lead) (*mediadownloader.Video, *transcriptor.GetTranscriptResponse, error) {
+	_, candidates, err := c.videoCandidates(ctx, lead)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range candidates {
+		video := &candidates[i]
+		if video.ID == string(lead.OpenerVideoID) {
+			return nil, nil, nil
+		}
+
+		var transcript *transcriptor.GetTranscriptResponse
+		transcript, err = c.videoTranscript(ctx, video)
+		if err == nil {
+			return video, transcript, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	// the recorded video isn't a candidate anymore and none of the newer
+	// ones has a transcript
+	return nil, nil, err
+}
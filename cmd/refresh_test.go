@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	airtable "github.com/bjornpagen/airtable-go"
+)
+
+func TestKeepApproved(t *testing.T) {
+	approved := &Lead{
+		Opener:        "i loved your video on kettlebell swings, the bit",
+		OpenerPrompt:  "opener@1",
+		OpenerVideoID: "old",
+		Status:        StatusApprovedOpener,
+		Attempts:      1,
+	}
+
+	// a refresh whose openers failed the checks
+	update := failedLead(StatusNeedsReview, approved, failStage(stageOpener, errClassRejected, "opener failed the checks 3 times", nil))
+	update.Opener = "i loved your latest video! new"
+	update.OpenerAlternates = "i loved your latest video! other"
+	update.OpenerPrompt = "opener@2"
+	update.OpenerVideoID = "new"
+
+	keepApproved(update, approved)
+
+	if update.Opener != approved.Opener {
+		t.Errorf("opener is %q, want the approved one", update.Opener)
+	}
+	if want := airtable.LongText("i loved your latest video! new\ni loved your latest video! other"); update.OpenerAlternates != want {
+		t.Errorf("alternates are %q, want %q", update.OpenerAlternates, want)
+	}
+	if update.Status != StatusNeedsReview || update.OpenerVideoID != "new" || update.OpenerPrompt != "" {
+		t.Errorf("after keepApproved: %+v", update)
+	}
+	if update.FailureStage != "" || update.FailureClass != "" || update.FailureMessage != "" || update.FailedAt != "" || update.Attempts != 0 || update.ClearFailure {
+		t.Errorf("failure fields written: %+v", update)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	airtable "github.com/bjornpagen/airtable-go"
+	"github.com/spf13/cobra"
+)
+
+var (
+	_requeueStages      []string
+	_requeueClasses     []string
+	_requeueOlderThan   time.Duration
+	_requeueNewerThan   time.Duration
+	_requeueMaxAttempts int
+)
+
+func init() {
+	requeueCmd.Flags().StringSliceVar(&_requeueStages, "stage", nil, "only requeue leads that failed in these stages (name, channel, videos, transcript, opener)")
+	requeueCmd.Flags().StringSliceVar(&_requeueClasses, "class", nil, "only requeue leads that failed with these error classes (e.g. rate-limit, no-transcript)")
+	requeueCmd.Flags().DurationVar(&_requeueOlderThan, "older-than", 0, "only requeue leads that failed at least this long ago")
+	requeueCmd.Flags().DurationVar(&_requeueNewerThan, "newer-than", 0, "only requeue leads that failed at most this long ago")
+	requeueCmd.Flags().IntVar(&_requeueMaxAttempts, "max-attempts", 3, "leave leads that already failed this many times")
+}
+
+// requeueTo maps every failed status to the ready status that retries it.
+var requeueTo = map[Status]Status{
+	StatusFailedName:   StatusReadyName,
+	StatusFailedOpener: StatusReadyOpener,
+	// rejected in review, a new opener is generated
+	StatusRejectedOpener: StatusReadyOpener,
+}
+
+type requeueFilter struct {
+	stages      map[string]bool
+	classes     map[errorClass]bool
+	olderThan   time.Duration
+	newerThan   time.Duration
+	maxAttempts int
+	now         time.Time
+}
+
+func (f *requeueFilter) match(lead *Lead) bool {
+	if len(f.stages) > 0 && !f.stages[string(lead.FailureStage)] {
+		return false
+	}
+	if len(f.classes) > 0 && !f.classes[errorClass(lead.FailureClass)] {
+		return false
+	}
+
+	if f.olderThan > 0 || f.newerThan > 0 {
+		failedAt, err := time.Parse(time.RFC3339, string(lead.FailedAt))
+		if err != nil {
+			// leads failed before failures were recorded count as old
+			return f.newerThan == 0
+		}
+
+		age := f.now.Sub(failedAt)
+		if f.olderThan > 0 && age < f.olderThan {
+			return false
+		}
+		if f.newerThan > 0 && age > f.newerThan {
+			return false
+		}
+	}
+
+	return true
+}
+
+func runRequeue(cmd *cobra.Command, args []string) {
+	c, err := newClient(cmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	f := &requeueFilter{
+		stages:      make(map[string]bool),
+		classes:     make(map[errorClass]bool),
+		olderThan:   _requeueOlderThan,
+		newerThan:   _requeueNewerThan,
+		maxAttempts: _requeueMaxAttempts,
+		now:         time.Now(),
+	}
+	for _, s := range _requeueStages {
+		f.stages[s] = true
+	}
+	for _, s := range _requeueClasses {
+		f.classes[errorClass(s)] = true
+	}
+
+	if err := c.requeue(cmd.Context(), f); err != nil {
+		log.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (c *Client) requeue(ctx context.Context, f *requeueFilter) error {
+	leads, err := callWithContext(ctx, func() ([]airtable.Record[Lead], error) {
+		return c.leadDb.List(func(lead *Lead) bool {
+			_, failed := requeueTo[lead.Status]
+			return failed && c.assignee.match(lead.Assignee) && f.match(lead)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get airtable leads: %w", err)
+	}
+
+	var records []airtable.Record[Lead]
+	poisoned := 0
+	for _, lead := range leads {
+		// poison leads keep failing, stop cycling them
+		if f.maxAttempts > 0 && int(lead.Fields.Attempts) >= f.maxAttempts {
+			poisoned++
+			continue
+		}
+
+		records = append(records, airtable.Record[Lead]{
+			ID:     lead.ID,
+			Fields: &Lead{Status: requeueTo[lead.Fields.Status]},
+		})
+	}
+
+	if poisoned > 0 {
+		log.Printf("skipping %d leads that failed %d or more times", poisoned, f.maxAttempts)
+	}
+
+	skipped, err := c.updateLeads(statusIndex(leads), records)
+	if err != nil {
+		return fmt.Errorf("failed to requeue leads: %w", err)
+	}
+	log.Printf("requeued %d leads", len(records)-len(skipped))
+
+	return nil
+}
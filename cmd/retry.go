@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// errorClass says why an external call failed, and whether retrying it
+// might help.
+type errorClass string
+
+const (
+	errClassRateLimit errorClass = "rate-limit"
+	errClassServer    errorClass = "server"
+	errClassTimeout   errorClass = "timeout"
+	errClassNetwork   errorClass = "network"
+	errClassClient    errorClass = "client"
+	errClassCanceled  errorClass = "canceled"
+	errClassPermanent errorClass = "permanent"
+)
+
+func (e errorClass) retryable() bool {
+	switch e {
+	case errClassRateLimit, errClassServer, errClassTimeout, errClassNetwork:
+		return true
+	}
+	return false
+}
+
+// the API libraries only report some status codes in the error text
+var statusCodeRegexp = regexp.MustCompile(`status code (\d{3})`)
+
+// classifyError sorts an error from one of the API clients into a class.
+// Unknown errors are permanent, so they aren't retried blindly.
+func classifyError(err error) errorClass {
+	var se *stageError
+	if errors.As(err, &se) {
+		return se.Class
+	}
+
+	var ce *callError
+	if errors.As(err, &ce) {
+		return ce.Class
+	}
+
+	var tooLong *promptTooLongError
+	if errors.As(err, &tooLong) {
+		return errClassPromptTooLong
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return errClassCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return errClassTimeout
+	}
+
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return classifyStatus(apiErr.HTTPStatusCode)
+	}
+
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return classifyStatus(reqErr.HTTPStatusCode)
+	}
+
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return classifyStatus(statusErr.StatusCode)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return errClassTimeout
+		}
+		return errClassNetwork
+	}
+
+	msg := strings.ToLower(err.Error())
+	if m := statusCodeRegexp.FindStringSubmatch(msg); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		return classifyStatus(code)
+	}
+
+	// errors of mediadownloader and transcriptor without a recorded status
+	// only carry the response body
+	switch {
+	case strings.Contains(msg, "too many requests") || strings.Contains(msg, "rate limit"):
+		return errClassRateLimit
+	case strings.Contains(msg, "timeout") || strings.Contains(msg, "deadline exceeded"):
+		return errClassTimeout
+	case strings.Contains(msg, "failed to execute request") || strings.Contains(msg, "failed to send request"):
+		return errClassNetwork
+	}
+
+	return errClassPermanent
+}
+
+func classifyStatus(code int) errorClass {
+	switch {
+	case code == 429:
+		return errClassRateLimit
+	case code == 408:
+		return errClassTimeout
+	case code >= 500:
+		return errClassServer
+	case code >= 400:
+		return errClassClient
+	}
+	return errClassPermanent
+}
+
+// callError is the final error of a retried call.
+type callError struct {
+	Op       string
+	Class    errorClass
+	Attempts int
+	Err      error
+}
+
+func (e *callError) Error() string {
+	return fmt.Sprintf("%s failed after %d attempt(s) (%s): %v", e.Op, e.Attempts, e.Class, e.Err)
+}
+
+func (e *callError) Unwrap() error {
+	return e.Err
+}
+
+// retryPolicy is an exponential backoff with full jitter. Every attempt
+// gets Timeout to finish.
+type retryPolicy struct {
+	Attempts  int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Timeout   time.Duration
+}
+
+const (
+	defaultRetryAttempts = 4
+	defaultCallTimeout   = 2 * time.Minute
+)
+
+func defaultRetryPolicy() retryPolicy {
+	return retryPolicy{
+		Attempts:  defaultRetryAttempts,
+		BaseDelay: time.Second,
+		MaxDelay:  30 * time.Second,
+		Timeout:   defaultCallTimeout,
+	}
+}
+
+func (p retryPolicy) delay(attempt int) time.Duration {
+	d := p.BaseDelay << (attempt - 1)
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// retry calls fn until it succeeds, fails with a permanent error, runs out
+// of attempts or ctx is done. Failures are returned as a *callError.
+func retry[T any](ctx context.Context, p retryPolicy, op string, fn func(ctx context.Context) (T, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		res, err := attemptWithTimeout(ctx, p.Timeout, fn)
+		if err == nil {
+			return res, nil
+		}
+
+		class := classifyError(err)
+		if ctx.Err() != nil {
+			class = errClassCanceled
+		}
+		if !class.retryable() || attempt >= p.Attempts {
+			return res, &callError{Op: op, Class: class, Attempts: attempt, Err: err}
+		}
+
+		d := p.delay(attempt)
+		log.Printf("%s failed (%s), retrying in %s: %s", op, class, d.Round(time.Millisecond), err.Error())
+
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+			return res, &callError{Op: op, Class: errClassCanceled, Attempts: attempt, Err: err}
+		}
+	}
+}
+
+func attemptWithTimeout[T any](ctx context.Context, timeout time.Duration, fn func(ctx context.Context) (T, error)) (T, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return fn(ctx)
+}
+
+// callWithContext runs fn, giving up when ctx is done. The lead stores
+// don't take a context, so an abandoned call keeps running in the
+// background until it returns.
+func callWithContext[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	type result struct {
+		v   T
+		err error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		v, err := fn()
+		done <- result{v, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.v, r.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestClassifyError(t *testing.T) {
+	// what mediadownloader and transcriptor return, see statusRecorder
+	notOK := func(body string) error {
+		return fmt.Errorf("http status code is not ok: %s", body)
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want errorClass
+	}{
+		{"stage error", failStage(stageTranscript, errClassNoTranscript, "no transcript", nil), errClassNoTranscript},
+		{"call error", fmt.Errorf("get transcript: %w", &callError{Class: errClassServer, Err: errors.New("boom")}), errClassServer},
+		{"prompt too long", &promptTooLongError{Model: "gpt-4", Tokens: 9000, Budget: 8000}, errClassPromptTooLong},
+		{"canceled", fmt.Errorf("call: %w", context.Canceled), errClassCanceled},
+		{"deadline", context.DeadlineExceeded, errClassTimeout},
+		{"openai rate limit", &openai.APIError{HTTPStatusCode: 429}, errClassRateLimit},
+		{"openai bad request", &openai.APIError{HTTPStatusCode: 400}, errClassClient},
+		{"openai request error", &openai.RequestError{HTTPStatusCode: 503}, errClassServer},
+		{"net timeout", &net.DNSError{Err: "i/o timeout", IsTimeout: true}, errClassTimeout},
+		{"net error", &net.DNSError{Err: "no such host"}, errClassNetwork},
+		{"json server error", &statusError{StatusCode: 500, Err: notOK(`{"message":"Internal Server Error"}`)}, errClassServer},
+		{"html bad gateway", &statusError{StatusCode: 502, Err: notOK("<html><body>502 Bad Gateway</body></html>")}, errClassServer},
+		{"status rate limit", &statusError{StatusCode: 429, Err: notOK(`{"message":"You have exceeded the rate limit"}`)}, errClassRateLimit},
+		{"status not found", &statusError{StatusCode: 404, Err: notOK(`{"message":"Transcript not available"}`)}, errClassClient},
+		{"wrapped status", fmt.Errorf("get transcript: %w", &statusError{StatusCode: 503, Err: notOK("")}), errClassServer},
+		{"body without status", notOK(`{"message":"Internal Server Error"}`), errClassPermanent},
+		{"body rate limit", notOK(`{"message":"Too many requests"}`), errClassRateLimit},
+		{"prospety status", errors.New("request failed with status code 503"), errClassServer},
+		{"request not sent", fmt.Errorf("failed to execute request: %w", errors.New("connection reset")), errClassNetwork},
+		{"junk", errors.New("failed to unmarshal response body"), errClassPermanent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyError(tt.err); got != tt.want {
+				t.Errorf("classifyError(%v) = %s, want %s", tt.err, got, tt.want)
+			}
+		})
+	}
+}
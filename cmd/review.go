@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	airtable "github.com/bjornpagen/airtable-go"
+	mediadownloader "github.com/bjornpagen/youtube-apis/mediadownloader"
+	transcriptor "github.com/bjornpagen/youtube-apis/transcriptor"
+	"github.com/spf13/cobra"
+)
+
+func runReview(cmd *cobra.Command, args []string) {
+	c, err := newClient(cmd, keyOpenAI, keyTranscriptor, keyMediadownloader)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := c.review(cmd.Context(), cmd.InOrStdin(), cmd.OutOrStdout()); err != nil {
+		log.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// reviewStatuses are the statuses of openers waiting for a human.
+var reviewStatuses = map[Status]bool{
+	StatusSuccessOpener: true,
+	StatusNeedsReview:   true,
+}
+
+// excerptLength is how much of the transcript a review shows, in runes.
+const excerptLength = 600
+
+// review walks through the generated openers one by one and writes every
+// decision to the lead right away, so quitting loses nothing.
+func (c *Client) review(ctx context.Context, in io.Reader, out io.Writer) error {
+	leads, err := callWithContext(ctx, func() ([]airtable.Record[Lead], error) {
+		return c.leadDb.List(func(lead *Lead) bool {
+			return reviewStatuses[lead.Status] && c.assignee.match(lead.Assignee)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get airtable leads: %w", err)
+	}
+
+	if len(leads) == 0 {
+		fmt.Fprintln(out, "no openers to review")
+		return nil
+	}
+
+	r := &reviewer{c: c, in: bufio.NewReader(in), out: out}
+	approved, rejected := 0, 0
+	for i, lead := range leads {
+		if ctx.Err() != nil {
+			break
+		}
+
+		decision, err := r.reviewLead(ctx, i+1, len(leads), lead)
+		if errors.Is(err, errQuitReview) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch decision {
+		case StatusApprovedOpener:
+			approved++
+		case StatusRejectedOpener:
+			rejected++
+		}
+	}
+
+	fmt.Fprintf(out, "\napproved %d, rejected %d of %d openers\n", approved, rejected, len(leads))
+	return nil
+}
+
+var errQuitReview = errors.New("review quit")
+
+type reviewer struct {
+	c   *Client
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// reviewLead shows lead until the reviewer decides, returning the status it
+// was given, or StatusNew if it was skipped.
+func (r *reviewer) reviewLead(ctx context.Context, n, total int, lead airtable.Record[Lead]) (Status, error) {
+	opener := string(lead.Fields.Opener)
+	alternates := splitAlternates(string(lead.Fields.OpenerAlternates))
+	problems := string(lead.Fields.FailureMessage)
+	promptVersion := string(lead.Fields.OpenerPrompt)
+	changed := false
+	// the video is only written back if the opener was regenerated from it
+	var regeneratedFrom *mediadownloader.Video
+
+	// show the video the opener was written about, or for leads from
+	// before it was recorded, the one it would be written about now
+	var video *mediadownloader.Video
+	var source *transcriptor.GetTranscriptResponse
+	var err error
+	if id := string(lead.Fields.OpenerVideoID); id != "" {
+		video = &mediadownloader.Video{ID: id, Title: string(lead.Fields.OpenerVideoTitle)}
+		source, err = r.c.videoTranscript(ctx, video)
+	} else {
+		video, source, err = r.c.openerSource(ctx, lead.Fields)
+	}
+
+	title, transcript := "(unavailable)", ""
+	if err != nil {
+		title = fmt.Sprintf("(unavailable: %s)", err.Error())
+	} else {
+		title, transcript = video.Title, source.String()
+	}
+
+	for {
+		fmt.Fprintf(r.out, "\n[%d/%d] %s (%s), %s, %s\n", n, total, lead.Fields.InferredName, lead.Fields.Name, lead.Fields.InferredNiche, lead.Fields.Status)
+		fmt.Fprintf(r.out, "video:    %s\n", title)
+		if transcript != "" {
+			fmt.Fprintf(r.out, "excerpt:  %s\n", transcriptExcerpt(opener, transcript))
+		}
+		if lead.Fields.Status == StatusNeedsReview && problems != "" {
+			fmt.Fprintf(r.out, "problems: %s\n", problems)
+		}
+		fmt.Fprintf(r.out, "opener:   %s\n", opener)
+		for i, alt := range alternates {
+			fmt.Fprintf(r.out, "  %d) %s\n", i+1, alt)
+		}
+		fmt.Fprint(r.out, "[a]pprove [e]dit [r]egenerate [x] reject [1-9] use alternate [s]kip [q]uit > ")
+
+		answer, err := r.readLine()
+		if err != nil {
+			return StatusNew, err
+		}
+
+		switch answer {
+		case "a":
+			return StatusApprovedOpener, r.decide(lead, StatusApprovedOpener, opener, alternates, promptVersion, changed, regeneratedFrom)
+		case "x":
+			return StatusRejectedOpener, r.decide(lead, StatusRejectedOpener, opener, alternates, promptVersion, changed, regeneratedFrom)
+		case "s":
+			return StatusNew, nil
+		case "q":
+			return StatusNew, errQuitReview
+		case "e":
+			fmt.Fprint(r.out, "new opener (empty keeps it) > ")
+			edited, err := r.readLine()
+			if err != nil {
+				return StatusNew, err
+			}
+			if edited != "" {
+				opener = edited
+				changed = true
+				problems = ""
+				if p := r.c.openerRules.check(opener, transcript); len(p) > 0 {
+					problems = (&openerRejection{Opener: opener, Problems: p}).Error()
+					fmt.Fprintf(r.out, "warning: the edited %s\n", problems)
+				}
+			}
+		case "r":
+			if transcript == "" {
+				fmt.Fprintln(r.out, "can't regenerate without the transcript")
+				continue
+			}
+			fmt.Fprintln(r.out, "regenerating...")
+			openers, version, err := r.c.genOpener(ctx, source)
+			var rejection *openerRejection
+			switch {
+			case errors.As(err, &rejection):
+				openers = append([]string{rejection.Opener}, rejection.Alternates...)
+				problems = rejection.Error()
+				fmt.Fprintf(r.out, "warning: the new openers failed the checks: %s\n", problems)
+			case err != nil:
+				fmt.Fprintf(r.out, "failed to regenerate: %s\n", err.Error())
+				continue
+			default:
+				problems = ""
+			}
+			opener, alternates, promptVersion = openers[0], openers[1:], version
+			changed, regeneratedFrom = true, video
+		default:
+			i, err := strconv.Atoi(answer)
+			if err != nil || i < 1 || i > len(alternates) {
+				fmt.Fprintln(r.out, "unknown choice")
+				continue
+			}
+			// swap the alternate in, keeping the current opener as one
+			opener, alternates[i-1] = alternates[i-1], opener
+			changed = true
+		}
+	}
+}
+
+// decide writes status to lead, with the opener if it was changed, and
+// the video it was regenerated from if that isn't nil.
+func (r *reviewer) decide(lead airtable.Record[Lead], status Status, opener string, alternates []string, promptVersion string, changed bool, video *mediadownloader.Video) error {
+	// an approved opener is done with, whatever it failed before
+	fields := &Lead{Status: status, ClearFailure: status == StatusApprovedOpener}
+	if changed {
+		fields.Opener = airtable.ShortText(opener)
+		fields.OpenerAlternates = airtable.LongText(strings.Join(alternates, "\n"))
+		fields.OpenerPrompt = airtable.ShortText(promptVersion)
+	}
+	if video != nil {
+		setOpenerVideo(fields, video)
+	}
+
+	rec := airtable.Record[Lead]{ID: lead.ID, Fields: fields}
+	skipped, err := r.c.updateLeads(statusIndex([]airtable.Record[Lead]{lead}), []airtable.Record[Lead]{rec})
+	if err != nil {
+		return fmt.Errorf("failed to update lead %s: %w", lead.ID, err)
+	}
+	if len(skipped) > 0 {
+		return fmt.Errorf("lead %s can't go from %s to %s", lead.ID, lead.Fields.Status, status)
+	}
+
+	fmt.Fprintf(r.out, "%s\n", status)
+	return nil
+}
+
+func (r *reviewer) readLine() (string, error) {
+	line, err := r.in.ReadString('\n')
+	if err == io.EOF && line == "" {
+		return "", errQuitReview
+	}
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read answer: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func splitAlternates(s string) []string {
+	var alternates []string
+	for _, alt := range strings.Split(s, "\n") {
+		if alt = strings.TrimSpace(alt); alt != "" {
+			alternates = append(alternates, alt)
+		}
+	}
+	return alternates
+}
+
+// transcriptExcerpt returns excerptLength runes of transcript around the
+// first detail opener mentions, or its start.
+func transcriptExcerpt(opener, transcript string) string {
+	start := 0
+	lower := strings.ToLower(transcript)
+	for _, w := range detailWords(opener) {
+		if i := strings.Index(lower, w); i >= 0 {
+			// back up a little to show what led to the detail
+			start = utf8.RuneCountInString(lower[:i]) - excerptLength/4
+			break
+		}
+	}
+	if start < 0 {
+		start = 0
+	}
+
+	runes := []rune(transcript)
+	if start > len(runes) {
+		start = len(runes)
+	}
+	end := start + excerptLength
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	excerpt := string(runes[start:end])
+	if start > 0 {
+		excerpt = "..." + excerpt
+	}
+	if end < len(runes) {
+		excerpt += "..."
+	}
+	return excerpt
+}
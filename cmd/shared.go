@@ -6,9 +6,11 @@ import (
 	"encoding/base64"
 	"encoding/gob"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	airtable "github.com/bjornpagen/airtable-go"
 	prospety "github.com/bjornpagen/prospety-go"
@@ -16,9 +18,9 @@ import (
 	openai "github.com/sashabaranov/go-openai"
 )
 
-func (c *Client) getProspects() ([]prospety.Prospect, error) {
+func (c *Client) getProspects(ctx context.Context) ([]prospety.Prospect, error) {
 	// Get all the searches
-	searches, err := c.pc.GetSearches()
+	searches, err := c.pc.GetSearches(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get projects: %w", err)
 	}
@@ -26,7 +28,7 @@ func (c *Client) getProspects() ([]prospety.Prospect, error) {
 	// For each search, get the any (underlying []YoutubeProspect), and coerce to []YoutubeProspect
 	var youtubeProspects []prospety.Prospect
 	for _, search := range searches {
-		prospects, err := c.pc.GetProspects(search.ID)
+		prospects, err := c.pc.GetProspects(ctx, search.ID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get prospects: %w", err)
 		}
@@ -37,9 +39,11 @@ func (c *Client) getProspects() ([]prospety.Prospect, error) {
 	return youtubeProspects, nil
 }
 
-func (c *Client) getAirtableLeads() ([]Lead, error) {
+func (c *Client) getAirtableLeads(ctx context.Context) ([]Lead, error) {
 	// get all leads
-	leads, err := c.leadDb.List()
+	leads, err := callWithContext(ctx, func() ([]airtable.Record[Lead], error) {
+		return c.leadDb.List(nil)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get leads: %w", err)
 	}
@@ -56,19 +60,48 @@ func (c *Client) getAirtableLeads() ([]Lead, error) {
 // Airtable Types
 
 type Lead struct {
-	Topic         airtable.SingleSelect `json:"Topic,omitempty"`
-	Name          airtable.ShortText    `json:"Name,omitempty"`
-	FollowersK    airtable.Number       `json:"Followers (K),omitempty"`
-	Platform      airtable.SingleSelect `json:"Platform,omitempty"`
-	Link          airtable.URL          `json:"Link,omitempty"`
-	Email         airtable.Email        `json:"Email,omitempty"`
-	Phone         airtable.Phone        `json:"Phone,omitempty"`
-	Gob           airtable.ShortText    `json:"Gob,omitempty"`
-	Opener        airtable.ShortText    `json:"Opener,omitempty"`
-	Assignee      *airtable.User        `json:"Assignee,omitempty"`
-	Status        airtable.ShortText    `json:"Status,omitempty"`
-	InferredName  airtable.ShortText    `json:"Inferred Name,omitempty"`
-	InferredNiche airtable.ShortText    `json:"Inferred Niche,omitempty"`
+	Topic      airtable.SingleSelect `json:"Topic,omitempty"`
+	Name       airtable.ShortText    `json:"Name,omitempty"`
+	FollowersK airtable.Number       `json:"Followers (K),omitempty"`
+	Platform   airtable.SingleSelect `json:"Platform,omitempty"`
+	Link       airtable.URL          `json:"Link,omitempty"`
+	Email      airtable.Email        `json:"Email,omitempty"`
+	Phone      airtable.Phone        `json:"Phone,omitempty"`
+	Gob        airtable.ShortText    `json:"Gob,omitempty"`
+	Opener     airtable.ShortText    `json:"Opener,omitempty"`
+	// the runner-up openers, best first, one per line
+	OpenerAlternates airtable.LongText  `json:"Opener Alternates,omitempty"`
+	Assignee         *airtable.User     `json:"Assignee,omitempty"`
+	Status           Status             `json:"Status,omitempty"`
+	InferredName     airtable.ShortText `json:"Inferred Name,omitempty"`
+	InferredNiche    airtable.ShortText `json:"Inferred Niche,omitempty"`
+
+	// the video the opener was written about, see setOpenerVideo.
+	// OpenerVideoPublished is estimated from OpenerVideoPublishedText, the
+	// relative time YouTube showed when the opener was written.
+	OpenerVideoID            airtable.ShortText `json:"Opener Video ID,omitempty"`
+	OpenerVideoTitle         airtable.ShortText `json:"Opener Video Title,omitempty"`
+	OpenerVideoPublished     airtable.ShortText `json:"Opener Video Published,omitempty"`
+	OpenerVideoPublishedText airtable.ShortText `json:"Opener Video Published Text,omitempty"`
+	OpenerVideoURL           airtable.URL       `json:"Opener Video URL,omitempty"`
+
+	// versions of the prompts that produced the inferred fields and the
+	// opener, see promptSet
+	NamePrompt   airtable.ShortText `json:"Name Prompt,omitempty"`
+	OpenerPrompt airtable.ShortText `json:"Opener Prompt,omitempty"`
+
+	// set when the lead fails a stage, see failedLead. They are text, not
+	// single selects: airtable rejects a select option it doesn't know,
+	// and new failure classes come with new stages.
+	FailureStage   airtable.ShortText `json:"Failure Stage,omitempty"`
+	FailureClass   airtable.ShortText `json:"Failure Class,omitempty"`
+	FailureMessage airtable.LongText  `json:"Failure Message,omitempty"`
+	FailedAt       airtable.ShortText `json:"Failed At,omitempty"`
+	Attempts       airtable.Number    `json:"Attempts,omitempty"`
+
+	// ClearFailure writes the failure fields as null, so a lead that
+	// succeeds after a requeue loses the failure of its last try.
+	ClearFailure bool `json:"-"`
 }
 
 type Activity struct {
@@ -81,12 +114,12 @@ type Activity struct {
 	Created                  airtable.ShortText `json:"Created"`
 }
 
-func NewLeadDB(c *airtable.Client) *airtable.Table[Lead] {
-	return airtable.NewTable[Lead](c, "appl2x7vwQfJClY42", "tblQcKRYGoq7kIxVN")
+func NewLeadDB(c *airtable.Client, cfg AirtableConfig) *airtable.Table[Lead] {
+	return airtable.NewTable[Lead](c, cfg.Base, cfg.LeadsTable)
 }
 
-func NewActivityDB(c *airtable.Client) *airtable.Table[Activity] {
-	return airtable.NewTable[Activity](c, "appl2x7vwQfJClY42", "tblfPpzBCMhjXRCJg")
+func NewActivityDB(c *airtable.Client, cfg AirtableConfig) *airtable.Table[Activity] {
+	return airtable.NewTable[Activity](c, cfg.Base, cfg.ActivityTable)
 }
 
 func prospectToLeadDetails(prospect prospety.Prospect) *Lead {
@@ -96,8 +129,14 @@ func prospectToLeadDetails(prospect prospety.Prospect) *Lead {
 		log.Fatalf("failed to encode prospect: %v", err)
 	}
 
+	// the first keyword is the topic, prospects without any get none
+	var topic string
+	if len(prospect.Keywords) > 0 {
+		topic = capitalizeFirst(prospect.Keywords[0])
+	}
+
 	return &Lead{
-		Topic:      airtable.SingleSelect(capitalizeFirst(prospect.Keywords[0])),
+		Topic:      airtable.SingleSelect(topic),
 		Name:       airtable.ShortText(prospect.Name),
 		FollowersK: airtable.Number(prospect.Subscribers / 1000),
 		Platform:   airtable.SingleSelect("YouTube"),
@@ -143,7 +182,11 @@ func decodeStringGob(s string) (p *prospety.Prospect, err error) {
 
 // AI stuff
 func capitalizeFirst(s string) string {
-	return strings.ToUpper(s[:1]) + s[1:]
+	if s == "" {
+		return s
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
 }
 
 func dump[T any](in T) (string, error) {
@@ -159,25 +202,77 @@ func dump[T any](in T) (string, error) {
 	return buf.String(), nil
 }
 
-func (c *Client) gpt(prompt string) (response string, err error) {
-	c.gptLimiter.Take()
-	res, err := c.oc.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: openai.GPT3Dot5Turbo,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: prompt,
-				},
+func (c *Client) gpt(ctx context.Context, stage, prompt string) (response string, err error) {
+	msg, err := c.chat(ctx, stage, userPrompt(c.llmConfig.model(stage), prompt), nil)
+	if err != nil {
+		return "", err
+	}
+
+	response = msg.Content
+	return response, nil
+}
+
+func userPrompt(model, prompt string) openai.ChatCompletionRequest {
+	return openai.ChatCompletionRequest{
+		Model: model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: prompt,
 			},
 		},
-	)
+	}
+}
 
+// chat sends req for stage with retries and returns the first choice.
+// Requests that don't fit the model's prompt budget aren't sent. Answers of
+// the cached stages to requests sent before are taken from the cache. If
+// check is set, it must accept the answer: rejected answers are returned
+// as its error and never cached.
+func (c *Client) chat(ctx context.Context, stage string, req openai.ChatCompletionRequest, check func(msg *openai.ChatCompletionMessage) error) (*openai.ChatCompletionMessage, error) {
+	if err := c.checkBudget(req); err != nil {
+		return nil, err
+	}
+
+	send := func() (openai.ChatCompletionResponse, error) {
+		res, err := retry(ctx, c.retry, "chat completion", func(ctx context.Context) (openai.ChatCompletionResponse, error) {
+			c.gptLimiter.Take()
+			return c.llm.CreateChatCompletion(ctx, req)
+		})
+		if err != nil {
+			return res, fmt.Errorf("failed to create chat completion: %w", err)
+		}
+		return res, nil
+	}
+
+	answer := func(res openai.ChatCompletionResponse) error {
+		if len(res.Choices) == 0 {
+			return errors.New("chat completion returned no choices")
+		}
+		if check == nil {
+			return nil
+		}
+		return check(&res.Choices[0].Message)
+	}
+
+	var res openai.ChatCompletionResponse
+	var err error
+	if cachedStages[stage] {
+		var key string
+		key, err = llmKey(req)
+		if err != nil {
+			return nil, err
+		}
+		res, err = cached(c.cache, cacheLLM, key, send, answer)
+	} else {
+		res, err = send()
+		if err == nil {
+			err = answer(res)
+		}
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to create chat completion: %w", err)
+		return nil, err
 	}
 
-	response = res.Choices[0].Message.Content
-	return response, nil
+	return &res.Choices[0].Message, nil
 }
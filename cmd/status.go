@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	airtable "github.com/bjornpagen/airtable-go"
+	"github.com/spf13/cobra"
+)
+
+func runStatus(cmd *cobra.Command, args []string) {
+	c, err := newClient(cmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := c.printStatus(cmd.Context()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (c *Client) printStatus(ctx context.Context) error {
+	leads, err := callWithContext(ctx, func() ([]airtable.Record[Lead], error) {
+		return c.leadDb.List(func(lead *Lead) bool {
+			return c.assignee.match(lead.Assignee)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get airtable leads: %w", err)
+	}
+
+	// count leads per status
+	counts := make(map[Status]int)
+	for _, lead := range leads {
+		counts[lead.Fields.Status]++
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tLEADS")
+
+	// declared states first, in pipeline order
+	for _, s := range statuses {
+		fmt.Fprintf(w, "%s\t%d\n", s, counts[s])
+		delete(counts, s)
+	}
+
+	// then anything that was set by hand to an undeclared value
+	var unknown []Status
+	for s := range counts {
+		unknown = append(unknown, s)
+	}
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i] < unknown[j] })
+	for _, s := range unknown {
+		fmt.Fprintf(w, "%s (unknown)\t%d\n", s, counts[s])
+	}
+
+	fmt.Fprintf(w, "total\t%d\n", len(leads))
+
+	return w.Flush()
+}
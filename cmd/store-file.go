@@ -0,0 +1,177 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	airtable "github.com/bjornpagen/airtable-go"
+)
+
+// fileLeadStore is a LeadStore kept in a single local JSON file, so the
+// pipeline can run offline or without an Airtable subscription.
+type fileLeadStore struct {
+	path string
+
+	mu      sync.Mutex
+	records []airtable.Record[Lead]
+}
+
+type fileLeadStoreData struct {
+	Records []airtable.Record[Lead] `json:"records"`
+}
+
+func openFileLeadStore(path string) (*fileLeadStore, error) {
+	if path == "" {
+		return nil, errors.New("file store requires a path")
+	}
+
+	s := &fileLeadStore{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		// start empty, the file is created on first write
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store file: %w", err)
+	}
+
+	var d fileLeadStoreData
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal store file: %w", err)
+	}
+	s.records = d.Records
+
+	return s, nil
+}
+
+func (s *fileLeadStore) List(filter LeadFilter) ([]airtable.Record[Lead], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []airtable.Record[Lead]
+	for _, rec := range s.records {
+		if filter.match(rec.Fields) {
+			records = append(records, copyRecord(rec))
+		}
+	}
+
+	return records, nil
+}
+
+func (s *fileLeadStore) Create(leads []Lead) ([]airtable.Record[Lead], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	var created []airtable.Record[Lead]
+	for i := range leads {
+		id, err := newRecordID()
+		if err != nil {
+			return created, err
+		}
+
+		lead := leads[i]
+		rec := airtable.Record[Lead]{ID: id, CreatedTime: &now, Fields: &lead}
+		s.records = append(s.records, rec)
+		created = append(created, copyRecord(rec))
+	}
+
+	if err := s.flush(); err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+func (s *fileLeadStore) Update(records []airtable.Record[Lead]) ([]airtable.Record[Lead], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := make(map[string]int, len(s.records))
+	for i, rec := range s.records {
+		index[rec.ID] = i
+	}
+
+	var updated []airtable.Record[Lead]
+	for _, rec := range records {
+		i, ok := index[rec.ID]
+		if !ok {
+			return updated, fmt.Errorf("record %s not found", rec.ID)
+		}
+
+		// marshal the patch with omitempty and unmarshal it over the
+		// existing fields, so only the set fields are overwritten
+		patch, err := json.Marshal(rec.Fields)
+		if err != nil {
+			return updated, fmt.Errorf("failed to marshal record %s: %w", rec.ID, err)
+		}
+		if err := json.Unmarshal(patch, s.records[i].Fields); err != nil {
+			return updated, fmt.Errorf("failed to patch record %s: %w", rec.ID, err)
+		}
+		// the failure is cleared now, don't write nulls with every flush
+		s.records[i].Fields.ClearFailure = false
+
+		updated = append(updated, copyRecord(s.records[i]))
+	}
+
+	if err := s.flush(); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// flush atomically rewrites the store file. s.mu must be held.
+func (s *fileLeadStore) flush() error {
+	data, err := json.MarshalIndent(fileLeadStoreData{Records: s.records}, "", "\t")
+	if err != nil {
+		return fmt.Errorf("failed to marshal store file: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp store file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write store file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close store file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace store file: %w", err)
+	}
+
+	return nil
+}
+
+func copyRecord(rec airtable.Record[Lead]) airtable.Record[Lead] {
+	lead := *rec.Fields
+	if lead.Assignee != nil {
+		assignee := *lead.Assignee
+		lead.Assignee = &assignee
+	}
+	rec.Fields = &lead
+	return rec
+}
+
+// newRecordID returns an Airtable-looking record ID.
+func newRecordID() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate record id: %w", err)
+	}
+	return "rec" + hex.EncodeToString(b), nil
+}
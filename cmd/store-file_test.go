@@ -0,0 +1,82 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	airtable "github.com/bjornpagen/airtable-go"
+)
+
+func TestFileLeadStoreUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leads.json")
+	s, err := openFileLeadStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := s.Create([]Lead{{
+		Name:           "FitBob Training",
+		Email:          "bob@fitbob.example",
+		Status:         StatusFailedOpener,
+		FailureStage:   stageTranscript,
+		FailureClass:   airtable.ShortText(errClassServer),
+		FailureMessage: "transcriptor is down",
+		FailedAt:       "2024-06-15T12:00:00Z",
+		Attempts:       2,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created[0].ID
+
+	// set fields are written, empty ones are left as they are
+	if _, err := s.Update([]airtable.Record[Lead]{{ID: id, Fields: &Lead{Status: StatusReadyOpener}}}); err != nil {
+		t.Fatal(err)
+	}
+	lead := getLead(t, s, id)
+	if lead.Status != StatusReadyOpener || lead.Name != "FitBob Training" || lead.FailureMessage != "transcriptor is down" {
+		t.Errorf("after a status patch: %+v", lead)
+	}
+
+	// a success clears the failure, but not the attempts
+	update := &Lead{Opener: "i loved your latest video! i", Status: StatusSuccessOpener, ClearFailure: true}
+	if _, err := s.Update([]airtable.Record[Lead]{{ID: id, Fields: update}}); err != nil {
+		t.Fatal(err)
+	}
+	lead = getLead(t, s, id)
+	if lead.FailureStage != "" || lead.FailureClass != "" || lead.FailureMessage != "" || lead.FailedAt != "" {
+		t.Errorf("failure not cleared: %+v", lead)
+	}
+	if lead.Opener == "" || lead.Attempts != 2 || lead.Email != "bob@fitbob.example" {
+		t.Errorf("after a success patch: %+v", lead)
+	}
+
+	// the patches survive reopening the file
+	s, err = openFileLeadStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened := getLead(t, s, id); *reopened != *lead {
+		t.Errorf("reopened lead = %+v, want %+v", reopened, lead)
+	}
+
+	if _, err := s.Update([]airtable.Record[Lead]{{ID: "recMissing", Fields: &Lead{Status: StatusReadyOpener}}}); err == nil {
+		t.Error("updating a missing record succeeded")
+	}
+}
+
+func getLead(t *testing.T, s *fileLeadStore, id string) *Lead {
+	t.Helper()
+
+	records, err := s.List(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range records {
+		if rec.ID == id {
+			return rec.Fields
+		}
+	}
+	t.Fatalf("no record %s", id)
+	return nil
+}
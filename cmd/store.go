@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+
+	airtable "github.com/bjornpagen/airtable-go"
+)
+
+// LeadStore is the storage backend behind Client.leadDb. Records keep the
+// airtable.Record shape regardless of backend, so Update only touches the
+// non-empty fields of each record, exactly like an Airtable PATCH.
+type LeadStore interface {
+	List(filter LeadFilter) ([]airtable.Record[Lead], error)
+	Create(leads []Lead) ([]airtable.Record[Lead], error)
+	Update(records []airtable.Record[Lead]) ([]airtable.Record[Lead], error)
+}
+
+// LeadFilter reports whether a lead should be returned by LeadStore.List.
+// A nil LeadFilter matches every lead.
+type LeadFilter func(lead *Lead) bool
+
+func (f LeadFilter) match(lead *Lead) bool {
+	return f == nil || f(lead)
+}
+
+const (
+	storeAirtable = "airtable"
+	storeFile     = "file"
+)
+
+func openLeadStore(backend, path string, db *airtable.Client, cfg AirtableConfig) (LeadStore, error) {
+	switch backend {
+	case storeAirtable:
+		if db == nil {
+			return nil, fmt.Errorf("airtable store requires %s", keyAirtable)
+		}
+		return &airtableLeadStore{t: NewLeadDB(db, cfg)}, nil
+	case storeFile:
+		return openFileLeadStore(path)
+	default:
+		return nil, fmt.Errorf("unknown store backend %q", backend)
+	}
+}
+
+// airtableLeadStore is the Airtable backed LeadStore.
+type airtableLeadStore struct {
+	t *airtable.Table[Lead]
+}
+
+func (s *airtableLeadStore) List(filter LeadFilter) ([]airtable.Record[Lead], error) {
+	records, err := s.t.List()
+	if err != nil {
+		return nil, err
+	}
+
+	// airtable-go can't filter server-side, so do it here
+	var filtered []airtable.Record[Lead]
+	for _, rec := range records {
+		if rec.Fields != nil && filter.match(rec.Fields) {
+			filtered = append(filtered, rec)
+		}
+	}
+
+	return filtered, nil
+}
+
+func (s *airtableLeadStore) Create(leads []Lead) ([]airtable.Record[Lead], error) {
+	return s.t.Create(leads)
+}
+
+func (s *airtableLeadStore) Update(records []airtable.Record[Lead]) ([]airtable.Record[Lead], error) {
+	return s.t.Update(records)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// How structured answers are requested from the model.
+const (
+	// outputTools forces a tool call whose arguments follow the schema
+	outputTools = "tools"
+	// outputJSON asks for a JSON object with the response_format option
+	outputJSON = "json"
+	// outputText asks in the prompt only, for models without either
+	outputText = "text"
+)
+
+func validOutputMode(mode string) bool {
+	return mode == outputTools || mode == outputJSON || mode == outputText
+}
+
+// errInvalidOutput is returned when the answer holds no JSON matching the
+// requested output.
+var errInvalidOutput = errors.New("no valid json in response")
+
+// jsonSchema is the subset of JSON schema the structured outputs need.
+type jsonSchema struct {
+	Type        string                `json:"type"`
+	Description string                `json:"description,omitempty"`
+	Properties  map[string]jsonSchema `json:"properties,omitempty"`
+	Items       *jsonSchema           `json:"items,omitempty"`
+	Required    []string              `json:"required,omitempty"`
+}
+
+// structuredOutput declares the answer a prompt should produce.
+type structuredOutput struct {
+	Name        string
+	Description string
+	Schema      jsonSchema
+}
+
+// gptStructured sends prompt and decodes the answer into out, following
+// the Client's output mode. When the model doesn't make the tool call or
+// rejects tools altogether, the answer text is searched for JSON instead.
+// If check is set, it must accept the decoded out for the answer to be
+// valid.
+func (c *Client) gptStructured(ctx context.Context, stage, prompt string, spec structuredOutput, out any, check func() error) error {
+	req := userPrompt(c.llmConfig.model(stage), prompt)
+
+	switch c.outputMode {
+	case outputTools:
+		req.Tools = []openai.Tool{{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        spec.Name,
+				Description: spec.Description,
+				Parameters:  spec.Schema,
+			},
+		}}
+		req.ToolChoice = openai.ToolChoice{
+			Type:     openai.ToolTypeFunction,
+			Function: openai.ToolFunction{Name: spec.Name},
+		}
+	case outputJSON:
+		req.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+		}
+	}
+
+	// decoding checks the answer, so invalid ones aren't cached
+	decode := func(msg *openai.ChatCompletionMessage) error {
+		text := msg.Content
+		for _, call := range msg.ToolCalls {
+			if call.Function.Name == spec.Name {
+				text = call.Function.Arguments
+				break
+			}
+		}
+		if err := decodeStructured(text, out); err != nil {
+			return err
+		}
+		if check != nil {
+			return check()
+		}
+		return nil
+	}
+
+	_, err := c.chat(ctx, stage, req, decode)
+	if err != nil && c.outputMode != outputText && classifyError(err) == errClassClient {
+		// most likely the model doesn't support tools or JSON mode
+		log.Printf("structured output request rejected, falling back to text: %s", err.Error())
+		_, err = c.chat(ctx, stage, userPrompt(req.Model, prompt), decode)
+	}
+	return err
+}
+
+// decodeStructured decodes the JSON in s into out, which must be a
+// pointer. out is only written if the JSON decodes.
+func decodeStructured(s string, out any) error {
+	obj, ok := extractJSON(s)
+	if !ok {
+		return errInvalidOutput
+	}
+
+	// decode into a fresh value, so out never holds part of a bad answer
+	v := reflect.New(reflect.TypeOf(out).Elem())
+	if err := json.Unmarshal([]byte(obj), v.Interface()); err != nil {
+		return fmt.Errorf("%w: %s", errInvalidOutput, err.Error())
+	}
+	reflect.ValueOf(out).Elem().Set(v.Elem())
+
+	return nil
+}
+
+// extractJSON finds the first complete JSON object in s, skipping prose
+// and code fences around it.
+func extractJSON(s string) (string, bool) {
+	for start := strings.IndexByte(s, '{'); start >= 0; {
+		if end := matchingBrace(s[start:]); end > 0 {
+			obj := s[start : start+end+1]
+			if json.Valid([]byte(obj)) {
+				return obj, true
+			}
+		}
+
+		next := strings.IndexByte(s[start+1:], '{')
+		if next < 0 {
+			break
+		}
+		start += next + 1
+	}
+
+	return "", false
+}
+
+// matchingBrace returns the index of the brace closing s[0], ignoring
+// braces inside strings, or -1.
+func matchingBrace(s string) int {
+	depth := 0
+	inString := false
+	escaped := false
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case escaped:
+			escaped = false
+		case inString && ch == '\\':
+			escaped = true
+		case ch == '"':
+			inString = !inString
+		case inString:
+		case ch == '{':
+			depth++
+		case ch == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+		ok   bool
+	}{
+		{"bare", `{"a":1}`, `{"a":1}`, true},
+		{"prose", "Sure! Here it is: {\"a\": 1}\nHope that helps.", `{"a": 1}`, true},
+		{"code fence", "```json\n{\"a\": {\"b\": [1, 2]}}\n```", `{"a": {"b": [1, 2]}}`, true},
+		{"braces in string", `{"a": "}{"} trailing`, `{"a": "}{"}`, true},
+		{"escaped quote", `{"a": "say \"}\""}`, `{"a": "say \"}\""}`, true},
+		{"invalid first", `{not json} then {"a": 2}`, `{"a": 2}`, true},
+		{"unclosed", `{"a": 1`, "", false},
+		{"none", "no json here", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := extractJSON(tt.in)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("extractJSON(%q) = %q, %t, want %q, %t", tt.in, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	transcriptor "github.com/bjornpagen/youtube-apis/transcriptor"
+)
+
+const defaultChunkTokens = 2000
+
+// maxReduceRounds bounds how often summaries that are still too long are
+// summarized again.
+const maxReduceRounds = 3
+
+type transcriptChunkPromptData struct {
+	// Part and Parts number the chunk, Start is where it begins in the
+	// video, e.g. "12:04".
+	Part  int
+	Parts int
+	Start string
+	Text  string
+}
+
+// transcriptChunk is a run of subtitles that fits the chunk budget.
+type transcriptChunk struct {
+	// Start is in seconds from the start of the video.
+	Start float64
+	Text  string
+}
+
+// chunkTranscript splits the subtitles into chunks of at most maxTokens
+// tokens as measured by count, cutting between subtitles. A subtitle longer
+// than a chunk is cut between words.
+func chunkTranscript(subtitles []transcriptor.Transcription, maxTokens int, count func(string) int) []transcriptChunk {
+	var chunks []transcriptChunk
+	var cur []string
+	var start float64
+	tokens := 0
+
+	flush := func() {
+		if len(cur) > 0 {
+			chunks = append(chunks, transcriptChunk{Start: start, Text: strings.Join(cur, " ")})
+		}
+		cur, tokens = nil, 0
+	}
+
+	for _, sub := range subtitles {
+		for _, part := range splitWords(strings.TrimSpace(sub.Subtitle), maxTokens, count) {
+			n := count(part) + 1
+			if tokens > 0 && tokens+n > maxTokens {
+				flush()
+			}
+			if len(cur) == 0 {
+				start = sub.Start
+			}
+			cur = append(cur, part)
+			tokens += n
+		}
+	}
+	flush()
+
+	return chunks
+}
+
+// splitWords cuts s between words into parts of at most maxTokens tokens.
+// A single word longer than that is a part of its own.
+func splitWords(s string, maxTokens int, count func(string) int) []string {
+	if s == "" {
+		return nil
+	}
+	if count(s) <= maxTokens {
+		return []string{s}
+	}
+
+	var parts []string
+	var cur []string
+	tokens := 0
+	for _, w := range strings.Fields(s) {
+		n := count(w) + 1
+		if tokens > 0 && tokens+n > maxTokens {
+			parts = append(parts, strings.Join(cur, " "))
+			cur, tokens = nil, 0
+		}
+		cur = append(cur, w)
+		tokens += n
+	}
+	if len(cur) > 0 {
+		parts = append(parts, strings.Join(cur, " "))
+	}
+	return parts
+}
+
+// formatTimestamp formats seconds as "m:ss", or "h:mm:ss" past an hour.
+func formatTimestamp(seconds float64) string {
+	s := int(seconds)
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+// transcriptNotesInput returns what the opener-notes prompt is asked about:
+// the whole transcript if it fits in a chunk, else the summaries of its
+// chunks in order, each with the time it starts at. Summaries still too
+// long after maxReduceRounds are trimmed to the prompt budget. It also
+// returns the version of the chunk prompt, empty if no summaries were
+// needed.
+func (c *Client) transcriptNotesInput(ctx context.Context, transcript *transcriptor.GetTranscriptResponse) (string, bool, string, error) {
+	notesModel := c.llmConfig.model(stageOpener)
+	notesBudget, err := c.inputBudget(stageOpener, promptOpenerNotes, openerNotesPromptData{Summarized: true})
+	if err != nil {
+		return "", false, "", err
+	}
+	notesTokens := c.chunkTokens
+	if notesBudget < notesTokens {
+		notesTokens = notesBudget
+	}
+
+	text := transcript.String()
+	if countTokens(notesModel, text) <= notesTokens {
+		return text, false, "", nil
+	}
+
+	summaryModel := c.llmConfig.model(stageSummary)
+	chunkBudget, err := c.inputBudget(stageSummary, promptTranscriptChunk, transcriptChunkPromptData{Part: 99, Parts: 99, Start: "00:00:00"})
+	if err != nil {
+		return "", false, "", err
+	}
+	chunkTokens := c.chunkTokens
+	if chunkBudget < chunkTokens {
+		chunkTokens = chunkBudget
+	}
+	if chunkTokens < 1 {
+		return "", false, "", fmt.Errorf("the %s prompt leaves no room for the transcript in the budget of %s", promptTranscriptChunk, summaryModel)
+	}
+	count := func(s string) int { return countTokens(summaryModel, s) }
+
+	chunks := chunkTranscript(transcript.Transcription, chunkTokens, count)
+	var version string
+	for round := 1; ; round++ {
+		log.Printf("summarizing %d transcript chunks, round %d", len(chunks), round)
+
+		summaries := make([]string, len(chunks))
+		for i, chunk := range chunks {
+			prompt, v, err := c.prompts.render(promptTranscriptChunk, transcriptChunkPromptData{
+				Part:  i + 1,
+				Parts: len(chunks),
+				Start: formatTimestamp(chunk.Start),
+				Text:  chunk.Text,
+			})
+			if err != nil {
+				return "", false, "", err
+			}
+			version = v
+
+			summary, err := c.gpt(ctx, stageSummary, prompt)
+			if err != nil {
+				return "", false, "", fmt.Errorf("failed to summarize transcript part %d/%d: %w", i+1, len(chunks), err)
+			}
+			summaries[i] = fmt.Sprintf("[%s] %s", formatTimestamp(chunk.Start), strings.TrimSpace(summary))
+		}
+
+		text = strings.Join(summaries, "\n\n")
+		if countTokens(notesModel, text) <= notesTokens {
+			return text, true, version, nil
+		}
+		if round == maxReduceRounds {
+			log.Printf("transcript summaries are still too long after %d rounds, trimming them to %d tokens", round, notesBudget)
+			return trimToTokens(notesModel, text, notesBudget), true, version, nil
+		}
+
+		// the summaries are too long still, summarize them again keeping
+		// the time of their first part
+		var subtitles []transcriptor.Transcription
+		for i, summary := range summaries {
+			subtitles = append(subtitles, transcriptor.Transcription{Subtitle: summary, Start: chunks[i].Start})
+		}
+		chunks = chunkTranscript(subtitles, chunkTokens, count)
+	}
+}
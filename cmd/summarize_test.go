@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	transcriptor "github.com/bjornpagen/youtube-apis/transcriptor"
+)
+
+// countWords counts a token per word, so chunk sizes are easy to follow.
+func countWords(s string) int {
+	return len(strings.Fields(s))
+}
+
+func TestChunkTranscript(t *testing.T) {
+	tests := []struct {
+		name      string
+		subtitles []transcriptor.Transcription
+		maxTokens int
+		want      []transcriptChunk
+	}{
+		{
+			name: "cuts between subtitles",
+			subtitles: []transcriptor.Transcription{
+				{Subtitle: "a b c", Start: 0},
+				{Subtitle: "d e", Start: 5},
+				{Subtitle: "  ", Start: 7},
+				{Subtitle: "f g h i", Start: 9},
+			},
+			maxTokens: 6,
+			want: []transcriptChunk{
+				{Start: 0, Text: "a b c"},
+				{Start: 5, Text: "d e"},
+				{Start: 9, Text: "f g h i"},
+			},
+		},
+		{
+			name: "joins short subtitles",
+			subtitles: []transcriptor.Transcription{
+				{Subtitle: "a", Start: 0},
+				{Subtitle: "b", Start: 1},
+				{Subtitle: "c", Start: 2},
+			},
+			maxTokens: 4,
+			want: []transcriptChunk{
+				{Start: 0, Text: "a b"},
+				{Start: 2, Text: "c"},
+			},
+		},
+		{
+			name: "cuts long subtitles between words",
+			subtitles: []transcriptor.Transcription{
+				{Subtitle: "a b c d e f", Start: 3},
+			},
+			maxTokens: 4,
+			want: []transcriptChunk{
+				{Start: 3, Text: "a b"},
+				{Start: 3, Text: "c d"},
+				{Start: 3, Text: "e f"},
+			},
+		},
+		{
+			name:      "empty",
+			maxTokens: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := chunkTranscript(tt.subtitles, tt.maxTokens, countWords)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chunkTranscript() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
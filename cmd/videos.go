@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	airtable "github.com/bjornpagen/airtable-go"
+	mediadownloader "github.com/bjornpagen/youtube-apis/mediadownloader"
+)
+
+// VideoPolicy decides which of a channel's videos an opener is written
+// about. Videos are tried newest first, or most viewed first within
+// PopularWindow, until one has a transcript.
+type VideoPolicy struct {
+	SkipShorts bool `toml:"skip_shorts"`
+	// SkipLive skips livestreams, their replays and premieres.
+	SkipLive bool `toml:"skip_live"`
+	// MinLength and MaxAge are ignored when zero, and for videos whose
+	// length or age can't be read.
+	MinLength time.Duration `toml:"min_length"`
+	MaxAge    time.Duration `toml:"max_age"`
+	// PopularWindow puts the videos published within it first, most viewed
+	// first. Zero keeps the newest video first.
+	PopularWindow time.Duration `toml:"popular_window"`
+	// Candidates is how many videos are tried before the lead fails for
+	// lack of a transcript.
+	Candidates int `toml:"candidates"`
+}
+
+func defaultVideoPolicy() VideoPolicy {
+	return VideoPolicy{
+		SkipShorts: true,
+		SkipLive:   true,
+		MinLength:  time.Minute,
+		Candidates: 3,
+	}
+}
+
+func (p VideoPolicy) validate() error {
+	switch {
+	case p.Candidates < 1:
+		return fmt.Errorf("candidates must be at least 1, got %d", p.Candidates)
+	case p.MinLength < 0 || p.MaxAge < 0 || p.PopularWindow < 0:
+		return fmt.Errorf("min_length, max_age and popular_window must not be negative")
+	}
+	return nil
+}
+
+// checkClaims fails if openers written under the policy would make a false
+// claim: with PopularWindow the video may not be the latest one, but the
+// prefix every opener must start with says it is.
+func (p VideoPolicy) checkClaims(r OpenerRules) error {
+	if p.PopularWindow > 0 && strings.Contains(r.Prefix, "latest") {
+		return fmt.Errorf("popular_window picks videos that aren't the latest, but openers must start with %q; change opener.prefix and the opener prompt to drop \"latest\"", r.Prefix)
+	}
+	return nil
+}
+
+// skipReason returns why v doesn't match the policy, empty if it does.
+func (p VideoPolicy) skipReason(v mediadownloader.Video) string {
+	if p.SkipShorts && isShort(v) {
+		return "short"
+	}
+	if p.SkipLive && isLive(v) {
+		return "live"
+	}
+	if length, ok := parseVideoLength(v.LengthText); ok && p.MinLength > 0 && length < p.MinLength {
+		return fmt.Sprintf("only %s long", length)
+	}
+	if age, ok := parseVideoAge(v.PublishedTimeText); ok && p.MaxAge > 0 && age > p.MaxAge {
+		return fmt.Sprintf("published %s", v.PublishedTimeText)
+	}
+	return ""
+}
+
+// candidates returns the videos matching the policy in the order they
+// are tried, at most Candidates of them. videos are newest first.
+func (p VideoPolicy) candidates(videos []mediadownloader.Video) []mediadownloader.Video {
+	var matching []mediadownloader.Video
+	for _, v := range videos {
+		if p.skipReason(v) == "" {
+			matching = append(matching, v)
+		}
+	}
+
+	if p.PopularWindow > 0 {
+		recent := func(v mediadownloader.Video) bool {
+			age, ok := parseVideoAge(v.PublishedTimeText)
+			return ok && age <= p.PopularWindow
+		}
+		sort.SliceStable(matching, func(i, j int) bool {
+			ri, rj := recent(matching[i]), recent(matching[j])
+			if ri != rj {
+				return ri
+			}
+			return ri && parseViewCount(matching[i].ViewCountText) > parseViewCount(matching[j].ViewCountText)
+		})
+	}
+
+	if len(matching) > p.Candidates {
+		matching = matching[:p.Candidates]
+	}
+	return matching
+}
+
+func isShort(v mediadownloader.Video) bool {
+	return strings.EqualFold(v.Type, "shorts") || strings.EqualFold(v.Type, "short") ||
+		strings.Contains(strings.ToLower(v.Title), "#shorts")
+}
+
+func isLive(v mediadownloader.Video) bool {
+	published := strings.ToLower(v.PublishedTimeText)
+	return v.IsLiveNow || strings.EqualFold(v.Type, "live") || strings.EqualFold(v.Type, "stream") ||
+		strings.HasPrefix(published, "streamed") || strings.HasPrefix(published, "premiere") ||
+		strings.HasPrefix(published, "scheduled")
+}
+
+// parseVideoLength reads a length text like "10:42" or "1:02:03".
+func parseVideoLength(s string) (time.Duration, bool) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+
+	var seconds int
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		seconds = seconds*60 + n
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+var ageUnits = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+	"month":  30 * 24 * time.Hour,
+	"year":   365 * 24 * time.Hour,
+}
+
+// parseVideoAge reads a published time text like "3 days ago" or
+// "Streamed 1 month ago".
+func parseVideoAge(s string) (time.Duration, bool) {
+	fields := strings.Fields(strings.ToLower(s))
+	for i := 0; i+2 < len(fields); i++ {
+		if fields[i+2] != "ago" {
+			continue
+		}
+		n, err := strconv.Atoi(fields[i])
+		if err != nil {
+			return 0, false
+		}
+		unit, ok := ageUnits[strings.TrimSuffix(fields[i+1], "s")]
+		if !ok {
+			return 0, false
+		}
+		return time.Duration(n) * unit, true
+	}
+	return 0, false
+}
+
+// parseViewCount reads a view count text like "48,211 views" or
+// "1.2M views", 0 if it can't.
+func parseViewCount(s string) int64 {
+	fields := strings.Fields(strings.ReplaceAll(s, ",", ""))
+	if len(fields) == 0 {
+		return 0
+	}
+	num := strings.ToUpper(fields[0])
+
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(num, "K"):
+		multiplier = 1e3
+	case strings.HasSuffix(num, "M"):
+		multiplier = 1e6
+	case strings.HasSuffix(num, "B"):
+		multiplier = 1e9
+	}
+	if multiplier > 1 {
+		num = num[:len(num)-1]
+	}
+
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	return int64(n * multiplier)
+}
+
+// setOpenerVideo records on lead the video its opener was written about.
+// YouTube only shows how long ago a video was published, so that text is
+// kept next to the publish date estimated from it.
+func setOpenerVideo(lead *Lead, video *mediadownloader.Video) {
+	lead.OpenerVideoID = airtable.ShortText(video.ID)
+	lead.OpenerVideoTitle = airtable.ShortText(video.Title)
+	lead.OpenerVideoURL = airtable.URL(videoURL(video.ID))
+	lead.OpenerVideoPublishedText = airtable.ShortText(video.PublishedTimeText)
+	lead.OpenerVideoPublished = airtable.ShortText(estimatePublished(video.PublishedTimeText, time.Now().UTC()))
+}
+
+// estimatePublished returns when a video published the relative time s
+// before now was published, only as precise as s: "3 days ago" gives a
+// day, "2 months ago" a month and "1 year ago" a year. It is empty if s
+// can't be read.
+func estimatePublished(s string, now time.Time) string {
+	age, ok := parseVideoAge(s)
+	if !ok {
+		return ""
+	}
+
+	published := now.Add(-age)
+	switch {
+	case age >= ageUnits["year"]:
+		return published.Format("2006")
+	case age >= ageUnits["month"]:
+		return published.Format("2006-01")
+	}
+	return published.Format(time.DateOnly)
+}
+
+func videoURL(id string) string {
+	return "https://www.youtube.com/watch?v=" + id
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	mediadownloader "github.com/bjornpagen/youtube-apis/mediadownloader"
+)
+
+func TestVideoPolicyCandidates(t *testing.T) {
+	// newest first, as mediadownloader lists them
+	videos := []mediadownloader.Video{
+		{ID: "short", Type: "shorts", LengthText: "0:45", PublishedTimeText: "1 day ago", ViewCountText: "90K views"},
+		{ID: "live", LengthText: "1:02:03", PublishedTimeText: "Streamed 2 days ago", ViewCountText: "5K views"},
+		{ID: "clip", LengthText: "0:30", PublishedTimeText: "3 days ago", ViewCountText: "1K views"},
+		{ID: "new", LengthText: "10:42", PublishedTimeText: "1 week ago", ViewCountText: "1,204 views"},
+		{ID: "popular", LengthText: "12:00", PublishedTimeText: "2 weeks ago", ViewCountText: "1.2M views"},
+		{ID: "month", LengthText: "8:10", PublishedTimeText: "1 month ago", ViewCountText: "48,211 views"},
+		{ID: "old", LengthText: "20:00", PublishedTimeText: "2 years ago", ViewCountText: "3M views"},
+		{ID: "unknown", LengthText: "", PublishedTimeText: "", ViewCountText: ""},
+	}
+
+	tests := []struct {
+		name   string
+		policy func(p *VideoPolicy)
+		want   []string
+	}{
+		{
+			name:   "default",
+			policy: func(p *VideoPolicy) {},
+			want:   []string{"new", "popular", "month"},
+		},
+		{
+			name: "everything",
+			policy: func(p *VideoPolicy) {
+				p.SkipShorts, p.SkipLive, p.MinLength, p.Candidates = false, false, 0, 3
+			},
+			want: []string{"short", "live", "clip"},
+		},
+		{
+			name:   "max age",
+			policy: func(p *VideoPolicy) { p.MaxAge, p.Candidates = 30*24*time.Hour, 10 },
+			// unreadable ages aren't skipped
+			want: []string{"new", "popular", "month", "unknown"},
+		},
+		{
+			name:   "popular window",
+			policy: func(p *VideoPolicy) { p.PopularWindow, p.Candidates = 21*24*time.Hour, 10 },
+			want:   []string{"popular", "new", "month", "old", "unknown"},
+		},
+		{
+			name:   "one candidate",
+			policy: func(p *VideoPolicy) { p.Candidates = 1 },
+			want:   []string{"new"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := defaultVideoPolicy()
+			tt.policy(&p)
+
+			var got []string
+			for _, v := range p.candidates(videos) {
+				got = append(got, v.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("candidates() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimatePublished(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in, want string
+	}{
+		{"3 days ago", "2024-06-12"},
+		{"Streamed 2 weeks ago", "2024-06-01"},
+		{"2 months ago", "2024-04"},
+		{"1 year ago", "2023"},
+		{"Premieres in 2 hours", ""},
+	}
+	for _, tt := range tests {
+		if got := estimatePublished(tt.in, now); got != tt.want {
+			t.Errorf("estimatePublished(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -3,17 +3,22 @@ module github.com/bjornpagen/caleb-jones-outreach
 go 1.20
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/bjornpagen/airtable-go v0.0.0-20230419201855-56961997633f
 	github.com/bjornpagen/prospety-go v0.0.0-20230419124505-35de688fdf3d
 	github.com/bjornpagen/youtube-apis v0.0.0-20230419215022-1915ede40cfd
 	github.com/davecgh/go-spew v1.1.1
-	github.com/sashabaranov/go-openai v1.8.0
+	github.com/pkoukk/tiktoken-go v0.1.8
+	github.com/pkoukk/tiktoken-go-loader v0.0.2
+	github.com/sashabaranov/go-openai v1.24.0
 	github.com/spf13/cobra v1.7.0
 	go.uber.org/ratelimit v0.2.0
 )
 
 require (
 	github.com/andres-erbsen/clock v0.0.0-20160526145045-9e14626cd129 // indirect
+	github.com/dlclark/regexp2 v1.10.0 // indirect
+	github.com/google/uuid v1.3.0 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 )